@@ -0,0 +1,58 @@
+package slab
+
+// AllocAligned alloc a []byte of size that starts at a multiple of align, align must be a power of 2.
+// If the []byte from Alloc is not aligned, a []byte of size+align-1 is alloc and the []byte starts inside it,
+// so it costs up to a larger class unless the chunks are aligned by WithAlignment of align or more.
+// Both go through Alloc, so the sizes that don't fit in maxSize follow WithStrict and WithOversizeAllocator,
+// the oversize allocator gets back the aligned []byte inside its own one if that is not aligned.
+// Release it by FreeAligned, which finds the chunk by the address.
+func (pool *AtomPool) AllocAligned(size, align int) []byte {
+	if align < 1 || align&(align-1) != 0 {
+		panic("slab.AtomPool: align must be a power of 2")
+	}
+	mem := pool.Alloc(size)
+	if mem == nil || alignOffset(mem, align) == 0 {
+		return mem
+	}
+	// a chunk of an arena, a guard page, a class exhausted and made by make() or an oversize []byte may not be aligned.
+	if pool.Owns(mem) || size > pool.maxSize && pool.oversizeFree != nil {
+		pool.Free(mem)
+	}
+	if mem = pool.Alloc(size + align - 1); mem == nil {
+		return nil
+	}
+	off := alignOffset(mem, align)
+	return mem[off : off+size]
+}
+
+// FreeAligned release a []byte that alloc from AllocAligned, the chunk is found by the address of mem.
+// The others are released by Free, such as the oversize ones.
+func (pool *AtomPool) FreeAligned(mem []byte) {
+	ptr := pointerOf(mem)
+	if p, i := pool.ownerOf(ptr); p != nil {
+		c := &p.classes[i]
+		pool.Free(c.chunkAt(c.chunkIndex(ptr)).mem)
+		return
+	}
+	pool.Free(mem)
+}
+
+// alignOffset returns the number of bytes need to skip from the beginning of mem to the align boundary.
+func alignOffset(mem []byte, align int) int {
+	return int(-pointerOf(mem) & uintptr(align-1))
+}
+
+// alignSizes round the chunk sizes up to multiples of align, the sizes merged or out of pageSize are dropped.
+func alignSizes(sizes []int, align, pageSize int) []int {
+	aligned := make([]int, 0, len(sizes))
+	for _, size := range sizes {
+		size = (size + align - 1) / align * align
+		if size > pageSize {
+			break
+		}
+		if len(aligned) == 0 || size > aligned[len(aligned)-1] {
+			aligned = append(aligned, size)
+		}
+	}
+	return aligned
+}
@@ -0,0 +1,100 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AllocAligned(t *testing.T) {
+	pool := NewAtomPool(100, 1000, 2, 4000)
+	for _, align := range []int{1, 8, 64, 256} {
+		mem := pool.AllocAligned(90, align)
+		utest.EqualNow(t, len(mem), 90)
+		utest.EqualNow(t, alignOffset(mem, align), 0)
+		utest.Assert(t, pool.classForPointer(pointerOf(mem)) >= 0)
+		pool.FreeAligned(mem)
+	}
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	mem := pool.AllocAligned(990, 4096)
+	utest.EqualNow(t, alignOffset(mem, 4096), 0)
+	pool.FreeAligned(mem)
+
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	pool.AllocAligned(100, 3)
+}
+
+func Test_WithAlignment(t *testing.T) {
+	pool := NewAtomPool(100, 1000, 2, 4096, WithAlignment(64))
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{128, 256, 448, 832, 1024})
+	for _, size := range pool.ClassSizes() {
+		mem := pool.AllocAligned(size, 64)
+		utest.EqualNow(t, alignOffset(mem, 64), 0)
+		pool.Free(mem)
+	}
+	for i := range pool.classes {
+		c := &pool.classes[i]
+		for k := 0; k < c.chunkCount(); k++ {
+			utest.EqualNow(t, alignOffset(c.chunkAt(k).mem, 64), 0)
+		}
+	}
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(0))
+
+	// the []byte made by make() for an exhausted class is aligned too.
+	var mems [][]byte
+	for i := 0; i < 50; i++ {
+		mem := pool.AllocAligned(100, 64)
+		utest.EqualNow(t, alignOffset(mem, 64), 0)
+		mems = append(mems, mem)
+	}
+	for _, mem := range mems {
+		pool.FreeAligned(mem)
+	}
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+
+	config := pool.Config()
+	utest.EqualNow(t, config.Alignment, 64)
+	pool2, err := NewAtomPoolFromConfig(config)
+	utest.IsNilNow(t, err)
+	utest.DeepEqualNow(t, pool2.ClassSizes(), pool.ClassSizes())
+}
+
+func Test_AllocAligned_Arena(t *testing.T) {
+	// the chunks are off the alignment if the arena is, WithAlignment only rounds the chunk sizes.
+	arena := make([]byte, 7*8192+8)[8:]
+	pool, err := NewAtomPoolOn(arena, 128, 8192, 2, WithAlignment(64))
+	utest.IsNilNow(t, err)
+	for _, align := range []int{64, 4096} {
+		mem := pool.AllocAligned(100, align)
+		utest.EqualNow(t, alignOffset(mem, align), 0)
+		pool.FreeAligned(mem)
+	}
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AllocAligned_Oversize(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithStrict())
+	utest.IsNilNow(t, pool.AllocAligned(2048, 64))
+	utest.EqualNow(t, pool.MaxOversize(), 2048)
+
+	var allocs, frees int
+	pool = NewAtomPool(128, 1024, 2, 1024, WithOversizeAllocator(func(size int) []byte {
+		allocs++
+		// an oversize []byte off the alignment, so it's over alloc.
+		return make([]byte, size+1)[1:]
+	}, func(mem []byte) {
+		frees++
+	}))
+	mem := pool.AllocAligned(2048, 64)
+	utest.EqualNow(t, len(mem), 2048)
+	utest.EqualNow(t, alignOffset(mem, 64), 0)
+	utest.EqualNow(t, allocs, 2)
+	utest.EqualNow(t, frees, 1)
+	pool.FreeAligned(mem)
+	utest.EqualNow(t, frees, 2)
+}
@@ -1,124 +1,1189 @@
 package slab
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
 	"reflect"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"unsafe"
 )
 
 // AtomPool is a lock-free slab allocation memory pool.
 type AtomPool struct {
-	classes []class
-	minSize int
-	maxSize int
+	fallbacks        uint64 // keep 64-bit aligned for atomic operations
+	maxOversize      uint64
+	trackIDs         uint64 // the last id given by track
+	lastFree         uint32 // class index found by the last Free, see classForFree
+	tracked          int32  // number of buffers alloc by AllocContext and not freed yet
+	trackShards      [trackShardCount]trackShard
+	expandMu         sync.Mutex
+	classes          []class
+	sizeIndex        []uint8 // class index of each minSize wide size range, see classForAlloc
+	minSize          int
+	maxSize          int
+	logger           Logger
+	oversizeAlloc    func(size int) []byte
+	oversizeFree     func(mem []byte)
+	larger           int // number of larger classes Alloc can try, see WithLargerClasses
+	pageSize         int
+	numaNode         int // NUMA node + 1 of the pages, see WithNUMANode
+	maxWaste         float64
+	limitWaste       bool
+	fifo             bool
+	guardPages       bool
+	osPages          bool
+	casStats         bool
+	depthCheck       bool
+	poisonOnAlloc    bool
+	spins            int
+	poisonPattern    byte
+	rateLimits       []RateLimit // see WithRateLimit
+	classCapFallback bool
+	options          []Option       // the options of the pool, GrowMax applies them to the new classes
+	grown            unsafe.Pointer // *AtomPool of the classes added by GrowMax
+	interceptor      func(size int) (newSize int, usePool bool)
+	epochs           bool
+	maxPages         int // a class can grow to, see WithMaxPages
+	mmap             bool
+	shards           int // number of free lists of each class, see WithShards
+	mapMu            sync.Mutex
+	mapped           [][]byte // the pages mapped by WithMmap, unmapped by Close
+	closed           bool     // set by Close of the mapped pages to stop the growth of WithMaxPages, guarded by expandMu
+	factor           int      // the growth factor of NewAtomPool, or 0 if the pool is not created by it, see Config
+	leakDetection    bool
+	strict           bool // see WithStrict
+	zeroing          ZeroPolicy
+	align            int    // the alignment of the chunks, see WithAlignment
+	strictFree       bool   // see WithStrictFree
+	pageGen          uint32 // the initial tag of the chunks of the pages added later, above the tags released by Shrink, guarded by expandMu
+	leakMu           sync.Mutex
+	allocStacks      map[uintptr]allocRecord // the chunks in use, see WithLeakDetection
+	freeStacks       map[uintptr]freeRecord  // the free chunks that were recorded in use
 }
 
 // NewAtomPool create a lock-free slab allocation memory pool.
 // minSize is the smallest chunk size.
-// maxSize is the lagest chunk size.
+// maxSize is the lagest chunk size, it's a class of its own when the growth from minSize doesn't hit it.
 // factor is used to control growth of chunk size.
 // pageSize is the memory size of each slab class.
-func NewAtomPool(minSize, maxSize, factor, pageSize int) *AtomPool {
-	pool := &AtomPool{make([]class, 0, 10), minSize, maxSize}
-	for chunkSize := minSize; chunkSize <= maxSize && chunkSize <= pageSize; chunkSize *= factor {
-		c := class{
-			size:   chunkSize,
-			page:   make([]byte, pageSize),
-			chunks: make([]chunk, pageSize/chunkSize),
-			head:   (1 << 32),
-		}
-		for i := 0; i < len(c.chunks); i++ {
-			chk := &c.chunks[i]
-			// lock down the capacity to protect append operation
-			chk.mem = c.page[i*chunkSize : (i+1)*chunkSize : (i+1)*chunkSize]
-			if i < len(c.chunks)-1 {
-				chk.next = uint64(i+1+1 /* index start from 1 */) << 32
-			} else {
-				c.pageBegin = uintptr(unsafe.Pointer(&c.page[0]))
-				c.pageEnd = uintptr(unsafe.Pointer(&chk.mem[0]))
+// options are applied in order before the slab classes created.
+func NewAtomPool(minSize, maxSize, factor, pageSize int, options ...Option) *AtomPool {
+	pool := newAtomPool(classSizes(minSize, maxSize, factor, pageSize), minSize, maxSize, pageSize, nil, options)
+	pool.factor = factor
+	return pool
+}
+
+// NewAtomPoolN create a lock-free slab allocation memory pool that has classCount geometrically spaced classes.
+// The growth factor is (maxSize/minSize)^(1/(classCount-1)), chunk sizes are rounded to integer.
+// The smallest chunk size is minSize and the largest is maxSize, a single class pool has only the maxSize class.
+// Like NewAtomPool, the chunk sizes larger than pageSize are not pooled.
+func NewAtomPoolN(minSize, maxSize, classCount, pageSize int, options ...Option) *AtomPool {
+	if classCount < 1 {
+		panic("slab.NewAtomPoolN: classCount must be at least 1")
+	}
+	if minSize < 1 || maxSize < minSize {
+		panic("slab.NewAtomPoolN: bad minSize or maxSize")
+	}
+	if classCount > maxSize-minSize+1 {
+		panic("slab.NewAtomPoolN: more classes than chunk sizes")
+	}
+	sizes := make([]int, 0, classCount)
+	if classCount == 1 {
+		sizes = append(sizes, maxSize)
+	} else {
+		factor := math.Pow(float64(maxSize)/float64(minSize), 1/float64(classCount-1))
+		for i := 0; i < classCount; i++ {
+			size := int(math.Floor(float64(minSize)*math.Pow(factor, float64(i)) + 0.5))
+			// keep the sizes increasing and leave room for the remaining classes.
+			if upper := maxSize - (classCount - 1 - i); size > upper || i == classCount-1 {
+				size = upper
+			}
+			if i > 0 && size <= sizes[i-1] {
+				size = sizes[i-1] + 1
 			}
+			sizes = append(sizes, size)
+		}
+	}
+	for len(sizes) > 0 && sizes[len(sizes)-1] > pageSize {
+		sizes = sizes[:len(sizes)-1]
+	}
+	return newAtomPool(sizes, minSize, maxSize, pageSize, nil, options)
+}
+
+// NewAtomPoolFunc create a lock-free slab allocation memory pool that the chunk sizes are generated by next.
+// The first chunk size is minSize, and next returns the chunk size after prev, it must be larger than prev.
+// Like NewAtomPool, a last class of exactly maxSize is added when next skips it,
+// and the chunk sizes larger than pageSize are not pooled.
+func NewAtomPoolFunc(minSize, maxSize, pageSize int, next func(prev int) int, options ...Option) *AtomPool {
+	if minSize < 1 || maxSize < minSize {
+		panic("slab.NewAtomPoolFunc: bad minSize or maxSize")
+	}
+	var sizes []int
+	for size := minSize; size <= maxSize && size <= pageSize; {
+		sizes = append(sizes, size)
+		n := next(size)
+		if n <= size {
+			panic("slab.NewAtomPoolFunc: next must return a larger size")
+		}
+		size = n
+	}
+	if len(sizes) > 0 && sizes[len(sizes)-1] < maxSize && maxSize <= pageSize {
+		sizes = append(sizes, maxSize)
+	}
+	return newAtomPool(sizes, minSize, maxSize, pageSize, nil, options)
+}
+
+// NewAtomPoolOn create a lock-free slab allocation memory pool that carve its classes out of arena.
+// The arena is split into equal pages, one for each class grows from minSize by factor up to maxSize.
+// The pool doesn't own the arena, the caller must keep it alive and unchanged while the pool is in use.
+// It returns an error if the arena can't hold at least one chunk for each class.
+func NewAtomPoolOn(arena []byte, minSize, maxSize, factor int, options ...Option) (*AtomPool, error) {
+	if minSize < 1 || maxSize < minSize || factor < 2 {
+		return nil, errors.New("slab.NewAtomPoolOn: bad minSize, maxSize or factor")
+	}
+	sizes := classSizes(minSize, maxSize, factor, maxSize)
+	pageSize := len(arena) / len(sizes)
+	if pageSize < sizes[len(sizes)-1] {
+		return nil, fmt.Errorf("slab.NewAtomPoolOn: arena of %d bytes is too small for %d classes up to %d bytes",
+			len(arena), len(sizes), sizes[len(sizes)-1])
+	}
+	return newAtomPool(sizes, minSize, maxSize, pageSize, arena, options), nil
+}
+
+// newAtomPool apply the options, then create a slab class for each chunk size in sizes.
+// The pages are carved out of arena if it's not nil.
+func newAtomPool(sizes []int, minSize, maxSize, pageSize int, arena []byte, options []Option) *AtomPool {
+	pool := &AtomPool{
+		minSize:  minSize,
+		maxSize:  maxSize,
+		pageSize: pageSize,
+		options:  options,
+	}
+	for _, option := range options {
+		option(pool)
+	}
+	if pool.align > 1 {
+		sizes = alignSizes(sizes, pool.align, pageSize)
+		if n := len(sizes); n > 0 && sizes[n-1] > maxSize {
+			// the maxSize class is rounded up too.
+			pool.maxSize = sizes[n-1]
 		}
-		pool.classes = append(pool.classes, c)
 	}
+	pool.classes = make([]class, len(sizes))
+	for i, size := range sizes {
+		if arena != nil {
+			pool.initClass(&pool.classes[i], size, arena[i*pageSize:(i+1)*pageSize:(i+1)*pageSize])
+		} else {
+			pool.initClass(&pool.classes[i], size, pool.newPage())
+		}
+	}
+	pool.sizeIndex = newSizeIndex(pool.classes, minSize, pool.maxSize)
+	pool.initLimits()
 	return pool
 }
 
+// newPage create a page for a slab class.
+// With WithOSPages, the backing memory is rounded up to the OS pages and the page starts at an OS page.
+// With WithAlignment, the page starts at the alignment.
+func (pool *AtomPool) newPage() []byte {
+	size := pool.pageSize
+	if pool.osPages {
+		osPageSize := os.Getpagesize()
+		size = (size + osPageSize - 1) / osPageSize * osPageSize
+	}
+	if pool.guardPages {
+		page := mmapGuarded(size)
+		if pool.numaNode > 0 {
+			bindNode(page, pool.numaNode-1)
+		}
+		// keep the page end on the trailing guard page.
+		return page[size-pool.pageSize:]
+	}
+	var page []byte
+	if pool.mmap {
+		page = pool.mapPage(size)
+		if pool.numaNode > 0 {
+			bindNode(page, pool.numaNode-1)
+		}
+	} else if pool.numaNode > 0 {
+		page = mmapNode(size, pool.numaNode-1)
+	} else if pool.osPages || pool.align > 1 {
+		align := pool.align
+		if pool.osPages && os.Getpagesize() > align {
+			align = os.Getpagesize()
+		}
+		page = make([]byte, size+align)
+		offset := alignOffset(page, align)
+		page = page[offset : offset+size : offset+size]
+	} else {
+		page = make([]byte, size)
+	}
+	return page[:pool.pageSize:pool.pageSize]
+}
+
+// initClass carve the chunks of class c from page and apply the options to it.
+func (pool *AtomPool) initClass(c *class, size int, page []byte) {
+	c.init(size, page)
+	if pool.limitWaste {
+		c.minAlloc = c.size - int(math.Floor(float64(c.size)*pool.maxWaste))
+	}
+	if pool.fifo {
+		c.fifo = newFifo(c)
+	}
+	c.casStats = pool.casStats
+	c.depthCheck = pool.depthCheck
+	c.spins = pool.spins
+	if pool.shards > 1 && c.fifo == nil {
+		c.shards = make([]shard, pool.shards)
+		c.spread()
+	}
+	c.epochs = pool.epochs
+	c.zeroOnFree = pool.zeroing == ZeroOnFree
+}
+
+// classSizes returns the chunk sizes grow from minSize by factor, up to maxSize and pageSize.
+// When maxSize is not a chunk size on the way, a last class of exactly maxSize is added,
+// so every size up to maxSize is pooled as long as maxSize fits in a page.
+func classSizes(minSize, maxSize, factor, pageSize int) []int {
+	n := 0
+	last := 0
+	for chunkSize := minSize; chunkSize > 0 && chunkSize <= maxSize && chunkSize <= pageSize; chunkSize = growSize(chunkSize, factor) {
+		n++
+		last = chunkSize
+	}
+	clamp := n > 0 && last < maxSize && maxSize <= pageSize
+	if clamp {
+		n++
+	}
+	sizes := make([]int, 0, n)
+	for chunkSize := minSize; chunkSize > 0 && chunkSize <= maxSize && chunkSize <= pageSize; chunkSize = growSize(chunkSize, factor) {
+		sizes = append(sizes, chunkSize)
+	}
+	if clamp {
+		sizes = append(sizes, maxSize)
+	}
+	return sizes
+}
+
+// growSize returns size * factor, or 0 if it overflows int, so the loops of the chunk sizes stop there
+// instead of wrap to a negative size. A size beyond int can't be a chunk size anyway.
+func growSize(size, factor int) int {
+	if factor > 0 && size > int(^uint(0)>>1)/factor {
+		return 0
+	}
+	return size * factor
+}
+
+// newClasses create a slab class for each chunk size in sizes.
+func newClasses(sizes []int, pageSize int) []class {
+	classes := make([]class, len(sizes))
+	for i, size := range sizes {
+		classes[i].init(size, make([]byte, pageSize))
+	}
+	return classes
+}
+
+// init carve the chunks from page, all chunks are linked into the class's free list.
+func (c *class) init(chunkSize int, page []byte) {
+	c.size = chunkSize
+	c.page = page
+	c.chunks = make([]chunk, len(page)/chunkSize)
+	c.head = (1 << 32)
+
+	for i := 0; i < len(c.chunks); i++ {
+		chk := &c.chunks[i]
+		// lock down the capacity to protect append operation
+		chk.mem = c.page[i*chunkSize : (i+1)*chunkSize : (i+1)*chunkSize]
+		if i < len(c.chunks)-1 {
+			chk.next = uint64(i+1+1 /* index start from 1 */) << 32
+		} else {
+			c.pageBegin = uintptr(unsafe.Pointer(&c.page[0]))
+			c.pageEnd = uintptr(unsafe.Pointer(&chk.mem[0]))
+		}
+		sanitizeFree(chk.mem)
+	}
+}
+
+// maxSizeIndex limits the memory used by the size index.
+const maxSizeIndex = 64 * 1024
+
+// newSizeIndex maps the size range (k*minSize, (k+1)*minSize] to the first class larger than k*minSize,
+// so classForAlloc no need to scan the classes from the smallest one.
+// It returns nil if the index would be too large.
+func newSizeIndex(classes []class, minSize, maxSize int) []uint8 {
+	if minSize <= 0 || maxSize <= 0 || len(classes) > 255 || (maxSize-1)/minSize >= maxSizeIndex {
+		return nil
+	}
+	index := make([]uint8, (maxSize-1)/minSize+1)
+	i := 0
+	for k := 0; k < len(index); k++ {
+		for i < len(classes) && classes[i].size <= k*minSize {
+			i++
+		}
+		index[k] = uint8(i)
+	}
+	return index
+}
+
 // Alloc try alloc a []byte from internal slab class if no free chunk in slab class Alloc will make one.
 func (pool *AtomPool) Alloc(size int) []byte {
-	if size <= pool.maxSize {
-		for i := 0; i < len(pool.classes); i++ {
-			if pool.classes[i].size >= size {
-				mem := pool.classes[i].Pop()
-				if mem != nil {
-					return mem[:size]
-				}
-				break
+	if pool.interceptor != nil {
+		return pool.intercept(size)
+	}
+	return pool.alloc(size)
+}
+
+// ErrExhausted is returned by TryAlloc when a pool of WithStrict can't alloc from its classes.
+var ErrExhausted = errors.New("slab.AtomPool: exhausted")
+
+// TryAlloc alloc a []byte like Alloc, but it returns ErrExhausted instead of a nil []byte for a pool of WithStrict.
+func (pool *AtomPool) TryAlloc(size int) ([]byte, error) {
+	mem := pool.Alloc(size)
+	if mem == nil {
+		return nil, ErrExhausted
+	}
+	return mem, nil
+}
+
+// intercept alloc size as the interceptor of WithAllocInterceptor decides.
+func (pool *AtomPool) intercept(size int) []byte {
+	newSize, usePool := pool.interceptor(size)
+	if !usePool {
+		atomic.AddUint64(&pool.fallbacks, 1)
+		if pool.logger != nil {
+			pool.logAlloc(size, nil, false)
+		}
+		if pool.strict {
+			return nil
+		}
+		return make([]byte, size)
+	}
+	if newSize < size {
+		newSize = size
+	}
+	if mem := pool.alloc(newSize); mem != nil {
+		return mem[:size]
+	}
+	return nil
+}
+
+func (pool *AtomPool) alloc(size int) []byte {
+	var c *class
+	if i := pool.classForAlloc(size); i >= 0 {
+		var mem []byte
+		if mem, c = pool.pop(i, size); mem != nil {
+			if pool.logger != nil {
+				pool.logAlloc(size, c, true)
+			}
+			return mem[:size]
+		}
+		atomic.AddUint64(&c.misses, 1)
+	} else if size > pool.maxSize {
+		if grown := pool.grownPool(); grown != nil {
+			return grown.alloc(size)
+		}
+	}
+	return pool.miss(size, c)
+}
+
+// miss count and log an Alloc of size that the slab class c, or no class if c is nil, can't serve,
+// and returns the []byte of fallback.
+func (pool *AtomPool) miss(size int, c *class) []byte {
+	atomic.AddUint64(&pool.fallbacks, 1)
+	if pool.logger != nil {
+		pool.logAlloc(size, c, false)
+	}
+	return pool.fallback(size)
+}
+
+// AllocAtLeast alloc a []byte of minLen and returns the capacity granted,
+// the []byte can be resliced up to capacity without reallocating.
+// If Alloc made the []byte, capacity is minLen.
+func (pool *AtomPool) AllocAtLeast(minLen int) (buf []byte, capacity int) {
+	buf = pool.Alloc(minLen)
+	return buf, cap(buf)
+}
+
+// AllocInto alloc a []byte like Alloc and store it into *dst, release it by Free as usual.
+// It saves returning the slice header in the hot code that keeps the []byte in a struct field or a local variable.
+func (pool *AtomPool) AllocInto(dst *[]byte, size int) {
+	*dst = pool.Alloc(size)
+}
+
+// AllocAny take a free chunk from any slab class, the larger classes first, and returns it with its size.
+// It's for the scratch buffers that don't need a exact size, it returns nil and 0 if no class has a free chunk.
+func (pool *AtomPool) AllocAny() ([]byte, int) {
+	for i := len(pool.classes) - 1; i >= 0; i-- {
+		c := &pool.classes[i]
+		if mem := c.Pop(); mem != nil {
+			if pool.logger != nil {
+				pool.logAlloc(c.size, c, true)
 			}
+			if pool.leakDetection {
+				pool.recordAlloc(mem)
+			}
+			return pool.poison(mem), c.size
+		}
+	}
+	return nil, 0
+}
+
+// Dup alloc a []byte of len(src) and copy src into it, the copy doesn't share memory with src.
+// It's the way to keep a view of a reused buffer, the copy can be released by Free.
+func (pool *AtomPool) Dup(src []byte) []byte {
+	mem := pool.Alloc(len(src))
+	copy(mem, src)
+	return mem
+}
+
+// Realloc resize mem that alloc from the pool to newSize, mem is resliced if newSize fits in its capacity.
+// Otherwise a []byte of newSize is alloc, the data of mem is copied into it and mem is released,
+// mem is only released after the copy so its chunk is never free while it's read.
+// The []byte returned replaces mem, mem must not be used after Realloc, a nil mem is alloc as Alloc.
+// For a pool of WithStrict it returns nil and leaves mem untouched if newSize can't be alloc.
+func (pool *AtomPool) Realloc(mem []byte, newSize int) []byte {
+	if newSize <= cap(mem) {
+		return mem[:newSize]
+	}
+	newMem := pool.Alloc(newSize)
+	if newMem == nil {
+		return nil
+	}
+	copy(newMem, mem)
+	if mem != nil {
+		pool.Free(mem)
+	}
+	return newMem
+}
+
+// With alloc a []byte of size and pass it to fn, the []byte is released after fn returns or panics.
+// fn must not keep the []byte or any slice of it after it returns.
+func (pool *AtomPool) With(size int, fn func([]byte)) {
+	mem := pool.Alloc(size)
+	defer pool.Free(mem)
+	fn(mem)
+}
+
+// AllocPair alloc two []byte in one call, e.g. a protocol header and its payload.
+// Both buffers are alloc as Alloc, through WithAllocInterceptor and the classes of GrowMax,
+// and both come from slab classes, or both are made by make() when one of them can't be pooled.
+// Release them with FreePair.
+func (pool *AtomPool) AllocPair(headerSize, payloadSize int) ([]byte, []byte) {
+	header := pool.Alloc(headerSize)
+	payload := pool.Alloc(payloadSize)
+	if pooled := pool.Owns(header); pooled != pool.Owns(payload) {
+		// put the pooled one back, so the pair don't hold a chunk that the other one missed.
+		if pooled {
+			pool.Free(header)
+			header = pool.miss(headerSize, nil)
+		} else {
+			pool.Free(payload)
+			payload = pool.miss(payloadSize, nil)
 		}
 	}
-	return make([]byte, size)
+	return header, payload
 }
 
 // Free release a []byte that alloc from Pool.Alloc.
+// The slab class is found by cap(mem), or by the address if mem is resliced to a smaller capacity,
+// so mem[:n:n] is released too, but mem must still start at the beginning of the chunk.
 func (pool *AtomPool) Free(mem []byte) {
-	size := cap(mem)
+	pool.free(mem, cap(mem))
+}
+
+// Recycle release a []byte like Free, and returns true if it's released into a slab class.
+// It returns false for the []byte made by make(), such as a fallback or oversize []byte, and Free ignores them safely,
+// so every []byte from Alloc can be passed to Free without remember which is pooled. See WithClassCapFallback.
+// The bad chunk and double free still panic as Free.
+func (pool *AtomPool) Recycle(mem []byte) bool {
+	return pool.free(mem, cap(mem))
+}
+
+// FreeAcross release mem into whichever of pools owns it by address, e.g. a []byte migrated from the pool of
+// another worker. It returns true if mem is released into a slab class, and false if no pool owns it,
+// the []byte not from any pool is left to the garbage collector.
+func FreeAcross(pools []*AtomPool, mem []byte) bool {
+	ptr := pointerOf(mem)
+	for _, pool := range pools {
+		for p := pool; p != nil; p = p.grownPool() {
+			if p.classForPointer(ptr) >= 0 {
+				return p.free(mem, cap(mem))
+			}
+		}
+	}
+	return false
+}
+
+// FreeCap release a []byte that alloc from Pool.Alloc but has been resliced to a smaller capacity.
+// originalCap is the capacity returned by Alloc, it's used to find the slab class instead of cap(mem),
+// and the chunk is still checked by the address of mem, so mem must start at the beginning of the chunk.
+// Free finds the class by the address too, FreeCap only saves the search when originalCap is known.
+func (pool *AtomPool) FreeCap(mem []byte, originalCap int) {
+	pool.free(mem, originalCap)
+}
+
+// free release mem into the class of chunk size, it returns true if mem is pooled.
+func (pool *AtomPool) free(mem []byte, size int) bool {
+	if atomic.LoadInt32(&pool.tracked) > 0 {
+		pool.untrack(mem, 0)
+	}
+	ptr := pointerOf(mem)
+	bySize := pool.classForFree(size)
+	i := bySize
+	if i < 0 || pool.classes[i].chunkIndex(ptr) < 0 {
+		// a []byte resliced to a smaller capacity is found by the address.
+		i = pool.classForPointer(ptr)
+	}
+	if i >= 0 {
+		if pool.leakDetection {
+			pool.recordFree(mem, &pool.classes[i])
+		}
+		ok := pool.classes[i].Push(mem)
+		if pool.logger != nil {
+			pool.logFree(size, &pool.classes[i], ok)
+		}
+		return ok
+	}
+	if grown := pool.grownPool(); grown != nil && (size > pool.maxSize || grown.classForPointer(ptr) >= 0) {
+		return grown.free(mem, size)
+	}
+	if pool.logger != nil {
+		var c *class
+		if bySize >= 0 {
+			c = &pool.classes[bySize]
+		}
+		pool.logFree(size, c, false)
+	}
+	if size > pool.maxSize && pool.oversizeFree != nil {
+		pool.oversizeFree(mem)
+		return false
+	}
+	if pool.strictFree && cap(mem) > 0 {
+		panic("slab.AtomPool: Foreign Free")
+	}
+	return false
+}
+
+// trackShardCount is the number of shards of the tracked []byte, so the Frees of different chunks rarely take the same lock.
+const trackShardCount = 16
+
+// trackShard is a part of the tracked []byte, keyed by the address.
+type trackShard struct {
+	mu      sync.Mutex
+	tracked map[uintptr]tracking
+}
+
+// tracking is the registration of a tracked []byte.
+type tracking struct {
+	id   uint64
+	stop chan struct{} // closed by untrack, or nil
+}
+
+// trackShardOf returns the shard that ptr is tracked in.
+func (pool *AtomPool) trackShardOf(ptr uintptr) *trackShard {
+	return &pool.trackShards[uint32(ptr>>4)*2654435761>>28%trackShardCount]
+}
+
+// track register mem to be freed by its owner, and returns the id of the registration.
+// stop is closed when mem is unregistered by untrack, it can be nil.
+func (pool *AtomPool) track(mem []byte, stop chan struct{}) uint64 {
+	id := atomic.AddUint64(&pool.trackIDs, 1)
+	ptr := pointerOf(mem)
+	shard := pool.trackShardOf(ptr)
+	shard.mu.Lock()
+	if shard.tracked == nil {
+		shard.tracked = make(map[uintptr]tracking)
+	}
+	shard.tracked[ptr] = tracking{id, stop}
+	atomic.AddInt32(&pool.tracked, 1)
+	shard.mu.Unlock()
+	return id
+}
+
+// untrack unregister mem and close its stop channel, it returns false if mem is not tracked.
+// If id is not 0, mem is only unregistered when it's tracked by the registration of id,
+// so a chunk freed by hand and tracked again is not unregistered by its old owner.
+func (pool *AtomPool) untrack(mem []byte, id uint64) bool {
+	ptr := pointerOf(mem)
+	shard := pool.trackShardOf(ptr)
+	shard.mu.Lock()
+	t, ok := shard.tracked[ptr]
+	if ok && id != 0 && t.id != id {
+		ok = false
+	}
+	if ok {
+		delete(shard.tracked, ptr)
+		atomic.AddInt32(&pool.tracked, -1)
+		if t.stop != nil {
+			close(t.stop)
+		}
+	}
+	shard.mu.Unlock()
+	return ok
+}
+
+// CapacityOf returns the chunk size of the slab class that mem points into, or -1 if mem is not from the pool.
+// It doesn't depend on cap(mem), so it works for the []byte that has been resliced to a smaller capacity.
+func (pool *AtomPool) CapacityOf(mem []byte) int {
+	if p, i := pool.ownerOf(pointerOf(mem)); p != nil {
+		return p.classes[i].size
+	}
+	return -1
+}
+
+// WouldAccept reports whether Free would release mem into a slab class, it doesn't change the pool.
+// mem must point to the beginning of a chunk in use, the capacity doesn't matter as Free.
+func (pool *AtomPool) WouldAccept(mem []byte) bool {
+	ptr := pointerOf(mem)
+	if p, i := pool.ownerOf(ptr); p != nil {
+		c := &p.classes[i]
+		j := c.chunkIndex(ptr)
+		return j >= 0 &&
+			uintptr(unsafe.Pointer(&c.chunkAt(j).mem[0])) == ptr &&
+			atomic.LoadUint64(&c.chunkAt(j).next) == 0
+	}
+	return false
+}
+
+// Owns reports whether mem points into a page of the pool, by the address and not the capacity.
+// A []byte that Owns is released by Free, it panics for a bad chunk if mem doesn't start a chunk.
+func (pool *AtomPool) Owns(mem []byte) bool {
+	p, _ := pool.ownerOf(pointerOf(mem))
+	return p != nil
+}
+
+// ownerOf returns the pool and the index of the slab class that ptr points into,
+// the classes added by GrowMax are searched too. It returns nil if ptr is not in the pool.
+func (pool *AtomPool) ownerOf(ptr uintptr) (*AtomPool, int) {
+	for p := pool; p != nil; p = p.grownPool() {
+		if i := p.classForPointer(ptr); i >= 0 {
+			return p, i
+		}
+	}
+	return nil, -1
+}
+
+// FreePair release two []byte that alloc from Pool.AllocPair.
+func (pool *AtomPool) FreePair(header, payload []byte) {
+	pool.Free(header)
+	pool.Free(payload)
+}
+
+// ClassSizes returns the chunk size of each slab class, from the smallest to the largest.
+func (pool *AtomPool) ClassSizes() []int {
+	sizes := make([]int, len(pool.classes))
+	for i := 0; i < len(pool.classes); i++ {
+		sizes[i] = pool.classes[i].size
+	}
+	if grown := pool.grownPool(); grown != nil {
+		sizes = append(sizes, grown.ClassSizes()...)
+	}
+	return sizes
+}
+
+// ChunkCounts returns the number of chunks of each slab class, in the same order as ClassSizes.
+// A class without chunk is useless, all Allocs of it are made by make().
+func (pool *AtomPool) ChunkCounts() []int {
+	counts := make([]int, len(pool.classes))
+	for i := 0; i < len(pool.classes); i++ {
+		counts[i] = pool.classes[i].chunkCount()
+	}
+	if grown := pool.grownPool(); grown != nil {
+		counts = append(counts, grown.ChunkCounts()...)
+	}
+	return counts
+}
+
+// MaxOversize returns the largest size requested by Alloc that exceeded maxSize, or 0 if there is none.
+// It tells how much maxSize needs to grow to pool all the allocations.
+func (pool *AtomPool) MaxOversize() int {
+	return int(atomic.LoadUint64(&pool.maxOversize))
+}
+
+// fallback make a []byte that can't be served by the slab classes,
+// the oversize one is made by the allocator of WithOversizeAllocator if there is.
+func (pool *AtomPool) fallback(size int) []byte {
+	if size > pool.maxSize {
+		pool.observeOversize(size)
+		if pool.oversizeAlloc != nil {
+			return pool.poison(pool.oversizeAlloc(size))
+		}
+	}
+	if pool.strict {
+		return nil
+	}
+	if pool.classCapFallback {
+		if i := pool.classForAlloc(size); i >= 0 {
+			return pool.poisonNew(make([]byte, size, pool.classes[i].size))
+		}
+	}
+	return pool.poisonNew(make([]byte, size))
+}
+
+func (pool *AtomPool) observeOversize(size int) {
+	for {
+		old := atomic.LoadUint64(&pool.maxOversize)
+		if uint64(size) <= old || atomic.CompareAndSwapUint64(&pool.maxOversize, old, uint64(size)) {
+			return
+		}
+	}
+}
+
+// Clear zero the memory of all free chunks, the free lists and the chunks in use are untouched.
+// Clear is safe to call while other goroutines alloc and free, Alloc will make new []byte when the class is being cleared.
+func (pool *AtomPool) Clear() {
 	for i := 0; i < len(pool.classes); i++ {
-		if pool.classes[i].size == size {
-			pool.classes[i].Push(mem)
+		pool.classes[i].Clear()
+	}
+}
+
+// The class selection of Alloc and Free lives in the functions below, so a []byte from Alloc is always found by Free.
+// classForAlloc and classForSize go through searchClass, classForFree goes through exactClass.
+
+// classForAlloc returns the index of the smallest slab class can hold size bytes,
+// or -1 if size larger than maxSize or the class wastes too much memory.
+func (pool *AtomPool) classForAlloc(size int) int {
+	i := pool.classForSize(size)
+	if i >= 0 && size < pool.classes[i].minAlloc {
+		return -1
+	}
+	return i
+}
+
+// classForSize returns the index of the smallest slab class can hold size bytes, or -1 if size larger than maxSize.
+func (pool *AtomPool) classForSize(size int) int {
+	if size > pool.maxSize {
+		return -1
+	}
+	from := 0
+	if pool.sizeIndex != nil && size > 0 {
+		from = int(pool.sizeIndex[(size-1)/pool.minSize])
+	}
+	return searchClass(pool.classes, from, size)
+}
+
+// classForFree returns the index of the slab class that chunk size equal to size, or -1 if there is none.
+// The class found last time is checked first, consecutive Frees often have the same size.
+func (pool *AtomPool) classForFree(size int) int {
+	last := int(atomic.LoadUint32(&pool.lastFree))
+	if last < len(pool.classes) && pool.classes[last].size == size {
+		return last
+	}
+	i := exactClass(pool.classes, size)
+	if i >= 0 {
+		atomic.StoreUint32(&pool.lastFree, uint32(i))
+	}
+	return i
+}
+
+// searchClass returns the index of the first class from the index from that can hold size bytes, or -1 if there is none.
+func searchClass(classes []class, from, size int) int {
+	for i := from; i < len(classes); i++ {
+		if classes[i].size >= size {
+			return i
+		}
+	}
+	return -1
+}
+
+// exactClass returns the index of the class that chunk size equal to size, or -1 if there is none.
+func exactClass(classes []class, size int) int {
+	for i := 0; i < len(classes); i++ {
+		if classes[i].size == size {
+			return i
+		}
+	}
+	return -1
+}
+
+// classForPointer returns the index of the slab class that ptr points into, or -1 if ptr is not in the pool.
+func (pool *AtomPool) classForPointer(ptr uintptr) int {
+	for i := 0; i < len(pool.classes); i++ {
+		if pool.classes[i].chunkIndex(ptr) >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// pop take a chunk from class i, if class i is empty it try the larger classes allowed by WithLargerClasses.
+// It returns the class that the chunk came from, or class i if there is no free chunk.
+// The chunk is recorded for WithLeakDetection.
+func (pool *AtomPool) pop(i, size int) ([]byte, *class) {
+	mem, c := pool.popChunk(i, size)
+	if mem != nil && pool.leakDetection {
+		pool.recordAlloc(mem)
+	}
+	return mem, c
+}
+
+// popChunk is pop without the record.
+func (pool *AtomPool) popChunk(i, size int) ([]byte, *class) {
+	c := &pool.classes[i]
+	if c.takeToken() {
+		for {
+			if mem := c.Pop(); mem != nil {
+				return pool.poison(mem), c
+			}
+			if pool.maxPages <= 1 || pool.fifo || len(c.chunks) == 0 || !pool.grow(c) {
+				break
+			}
+		}
+	}
+	for j := i + 1; j <= i+pool.larger && j < len(pool.classes); j++ {
+		if size < pool.classes[j].minAlloc {
 			break
 		}
+		if !pool.classes[j].takeToken() {
+			continue
+		}
+		if mem := pool.classes[j].Pop(); mem != nil {
+			return pool.poison(mem), &pool.classes[j]
+		}
 	}
+	return nil, c
 }
 
+// poison fill the whole capacity of a reused mem with the pattern of WithPoisonOnAlloc, or zero it for ZeroOnAlloc.
+func (pool *AtomPool) poison(mem []byte) []byte {
+	if pool.zeroing == ZeroOnAlloc && !pool.poisonOnAlloc {
+		zero(mem[:cap(mem)])
+		return mem
+	}
+	return pool.poisonNew(mem)
+}
+
+// poisonNew fill the whole capacity of mem made by make() with the pattern of WithPoisonOnAlloc,
+// it's not zeroed since it's zero already.
+func (pool *AtomPool) poisonNew(mem []byte) []byte {
+	if pool.poisonOnAlloc {
+		full := mem[:cap(mem)]
+		for i := range full {
+			full[i] = pool.poisonPattern
+		}
+	}
+	return mem
+}
+
+// class keeps the 64-bit fields of atomic operations first, they're 64-bit aligned at the beginning of the element
+// of AtomPool.classes, and the size of class is a multiple of 8 on the 32-bit platforms too, see Test_Alignment.
 type class struct {
-	size      int
-	page      []byte
-	pageBegin uintptr
-	pageEnd   uintptr
-	chunks    []chunk
-	head      uint64
+	head         uint64
+	pops         uint64 // chunks taken out of the free list
+	pushes       uint64 // chunks linked back into the free list
+	resetPops    uint64 // pops at the last ResetStats
+	resetPushes  uint64 // pushes at the last ResetStats
+	misses       uint64 // Allocs of the class made by make() since the class is empty
+	badFrees     uint64 // Frees panicked for a bad chunk or a double free
+	casAttempts  uint64 // CAS on head tried by Push and Pop, see WithCASStats
+	casSuccesses uint64 // CAS on head succeeded
+	size         int
+	minAlloc     int // the smallest size allowed by WithMaxWaste
+	page         []byte
+	pageBegin    uintptr
+	pageEnd      uintptr
+	chunks       []chunk
+	extra        unsafe.Pointer // *[]*extraPage, the pages added by Expand, replaced as a whole
+	casStats     bool
+	depthCheck   bool
+	epochs       bool
+	zeroOnFree   bool     // see WithZeroing
+	spins        int      // failed CASes retried at once before yielding, see WithSpin
+	fifo         *fifo    // replace the free list when WithFIFO
+	shards       []shard  // split the free list when WithShards, head is still used for the pages added later
+	limit        *limiter // the rate limit of Alloc, see WithRateLimit
 }
 
+// chunk is linked into the free list by next, the index of next chunk is packed with its ABA tag.
+// An array stack of indexes saves loading next in Pop, but it needs two atomic operations per Push and Pop
+// and is slower than the single CAS of the linked list, see Benchmark_FreeList_*.
+// The next is the first field and the padding rounds chunk up to a multiple of 8 bytes on the 32-bit platforms,
+// so next is 64-bit aligned in every element of the chunk slices for the atomic operations.
 type chunk struct {
-	mem  []byte
-	aba  uint32 // reslove ABA problem
-	next uint64
+	next  uint64
+	mem   []byte
+	aba   uint32 // reslove ABA problem
+	epoch uint32 // bumped by every Free when WithEpochs, see WeakRef
+	_     [(8 - unsafe.Sizeof([]byte(nil))%8) % 8]byte
 }
 
-func (c *class) Push(mem []byte) {
-	ptr := (*reflect.SliceHeader)(unsafe.Pointer(&mem)).Data
-	if c.pageBegin <= ptr && ptr <= c.pageEnd {
-		i := (ptr - c.pageBegin) / uintptr(c.size)
-		chk := &c.chunks[i]
-		if chk.next != 0 {
-			panic("slab.AtomPool: Double Free")
+// chunkIndex returns the index of the chunk that ptr points into, or -1 if ptr is not in the class's pages.
+func (c *class) chunkIndex(ptr uintptr) int {
+	if c.pageBegin <= ptr && ptr < c.pageEnd+uintptr(c.size) {
+		return int((ptr - c.pageBegin) / uintptr(c.size))
+	}
+	if atomic.LoadPointer(&c.extra) != nil {
+		for i, p := range c.extraPages() {
+			if p.begin <= ptr && ptr < p.end+uintptr(c.size) {
+				return (i+1)*len(c.chunks) + int((ptr-p.begin)/uintptr(c.size))
+			}
+		}
+	}
+	return -1
+}
+
+// chunkAt returns the chunk of index i, the chunks of the pages added by Expand follow the first page's.
+func (c *class) chunkAt(i int) *chunk {
+	if i < len(c.chunks) {
+		return &c.chunks[i]
+	}
+	pages := c.extraPages()
+	if k := i/len(c.chunks) - 1; k < len(pages) {
+		return &pages[k].chunks[i%len(c.chunks)]
+	}
+	return &releasedChunk
+}
+
+// chunkCount returns the number of chunks in all pages of the class.
+func (c *class) chunkCount() int {
+	return (len(c.extraPages()) + 1) * len(c.chunks)
+}
+
+// Push link the chunk of mem into the free list, it returns false if mem is not in the class's page.
+// A mem in the page but not point to the beginning of a chunk is a bad chunk.
+// mem may be zero length, so Push never index into mem but only the chunk's own full length slice.
+func (c *class) Push(mem []byte) bool {
+	ptr := pointerOf(mem)
+	if i := c.chunkIndex(ptr); i >= 0 {
+		chk := c.chunkAt(i)
+		c.release(chk, ptr)
+		if c.fifo != nil {
+			atomic.StoreUint64(&chk.next, fifoQueued)
+			c.fifo.Enqueue(uint64(i))
+			atomic.AddUint64(&c.pushes, 1)
+			return true
+		}
+		head, pushes := &c.head, &c.pushes
+		if c.shards != nil {
+			s := &c.shards[shardHint(len(c.shards))]
+			head, pushes = &s.head, &s.pushes
 		}
 		chk.aba++
 		new := uint64(i+1)<<32 + uint64(chk.aba)
-		for {
-			old := atomic.LoadUint64(&c.head)
+		for spins := 0; ; {
+			old := atomic.LoadUint64(head)
 			atomic.StoreUint64(&chk.next, old)
-			if atomic.CompareAndSwapUint64(&c.head, old, new) {
+			if c.casStats {
+				atomic.AddUint64(&c.casAttempts, 1)
+			}
+			if atomic.CompareAndSwapUint64(head, old, new) {
 				break
 			}
-			runtime.Gosched()
+			if spins < c.spins {
+				spins++
+			} else {
+				runtime.Gosched()
+			}
+		}
+		if c.casStats {
+			atomic.AddUint64(&c.casSuccesses, 1)
+		}
+		atomic.AddUint64(pushes, 1)
+		return true
+	}
+	return false
+}
+
+// release check the chunk freed by ptr and mark it freed, before it's linked into the free list.
+func (c *class) release(chk *chunk, ptr uintptr) {
+	if chunkChecks {
+		if base := uintptr(unsafe.Pointer(&chk.mem[0])); base != ptr {
+			atomic.AddUint64(&c.badFrees, 1)
+			if ptr == base+NodeHeaderSize {
+				// the payload of a node, measured from the chunk base like FreeNode.
+				if atomic.LoadUint64(&chk.next) != 0 {
+					panic("slab.AtomPool: Double Free")
+				}
+				panic("slab.AtomPool: Bad Chunk, release the node by FreeNode")
+			}
+			panic("slab.AtomPool: Bad Chunk")
+		}
+		if chk.next != 0 {
+			atomic.AddUint64(&c.badFrees, 1)
+			panic("slab.AtomPool: Double Free")
+		}
+	}
+	if c.depthCheck {
+		// pushes is loaded before pops, so the chunks in use may be over counted but never under counted.
+		pushes := c.loadPushes()
+		if c.free(c.loadPops(), pushes) == c.chunkCount() {
+			atomic.AddUint64(&c.badFrees, 1)
+			panic("slab.AtomPool: Bad Chunk")
 		}
 	}
+	if c.epochs {
+		atomic.AddUint32(&chk.epoch, 1)
+	}
+	if c.zeroOnFree {
+		zero(chk.mem)
+	}
+	sanitizeFree(chk.mem)
 }
 
 func (c *class) Pop() []byte {
-	for {
-		old := atomic.LoadUint64(&c.head)
+	if c.fifo != nil {
+		i, ok := c.fifo.Dequeue()
+		if !ok {
+			return nil
+		}
+		chk := c.chunkAt(int(i))
+		atomic.StoreUint64(&chk.next, 0)
+		atomic.AddUint64(&c.pops, 1)
+		sanitizeAlloc(chk.mem)
+		return chk.mem
+	}
+	if c.shards != nil {
+		return c.popShards()
+	}
+	return c.popFrom(&c.head, &c.pops)
+}
+
+// popFrom take a chunk from the free list of head, pops counts the chunks taken.
+func (c *class) popFrom(head, pops *uint64) []byte {
+	for spins := 0; ; {
+		old := atomic.LoadUint64(head)
 		if old == 0 {
 			return nil
 		}
-		chk := &c.chunks[old>>32-1]
+		chk := c.chunkAt(int(old>>32 - 1))
 		nxt := atomic.LoadUint64(&chk.next)
-		if atomic.CompareAndSwapUint64(&c.head, old, nxt) {
+		if c.casStats {
+			atomic.AddUint64(&c.casAttempts, 1)
+		}
+		if atomic.CompareAndSwapUint64(head, old, nxt) {
 			atomic.StoreUint64(&chk.next, 0)
+			if c.casStats {
+				atomic.AddUint64(&c.casSuccesses, 1)
+			}
+			atomic.AddUint64(pops, 1)
+			sanitizeAlloc(chk.mem)
 			return chk.mem
 		}
+		if spins < c.spins {
+			spins++
+		} else {
+			runtime.Gosched()
+		}
+	}
+}
+
+// Clear detach the whole free list, zero the chunks on it and link them back.
+// With WithFIFO, the free chunks are taken out one by one and put back after zeroed.
+func (c *class) Clear() {
+	if c.fifo != nil {
+		for n := c.fifo.Len(); n > 0; n-- {
+			i, ok := c.fifo.Dequeue()
+			if !ok {
+				return
+			}
+			zeroFree(c.chunkAt(int(i)).mem)
+			c.fifo.Enqueue(i)
+		}
+		return
+	}
+
+	old := c.detach()
+	if old == 0 {
+		return
+	}
+	var tail *chunk
+	for i := old >> 32; i != 0; i = atomic.LoadUint64(&tail.next) >> 32 {
+		tail = c.chunkAt(int(i - 1))
+		zeroFree(tail.mem)
+	}
+	c.attach(int(old>>32-1), tail)
+}
+
+// detach take the whole free list out of the class and returns its head, or 0 if the list is empty.
+// The free lists of WithShards are taken out too and chained after it.
+func (c *class) detach() uint64 {
+	first := swapHead(&c.head)
+	if c.shards == nil {
+		return first
+	}
+	var tail *chunk
+	if first != 0 {
+		tail = c.listTail(first)
+	}
+	for i := range c.shards {
+		head := swapHead(&c.shards[i].head)
+		if head == 0 {
+			continue
+		}
+		if tail == nil {
+			first = head
+		} else {
+			atomic.StoreUint64(&tail.next, head)
+		}
+		tail = c.listTail(head)
+	}
+	return first
+}
+
+// swapHead swap the head of a free list to 0 and returns the old head.
+func swapHead(head *uint64) uint64 {
+	for {
+		old := atomic.LoadUint64(head)
+		if old == 0 || atomic.CompareAndSwapUint64(head, old, 0) {
+			return old
+		}
 		runtime.Gosched()
 	}
 }
+
+// listTail returns the last chunk of a detached free list.
+func (c *class) listTail(head uint64) *chunk {
+	var tail *chunk
+	for i := head >> 32; i != 0; i = atomic.LoadUint64(&tail.next) >> 32 {
+		tail = c.chunkAt(int(i - 1))
+	}
+	return tail
+}
+
+// attach link a list of chunks from the chunk of index first to tail back into the free list,
+// the list is spread over the shards of WithShards.
+func (c *class) attach(first int, tail *chunk) {
+	if c.shards != nil {
+		c.attachShards(first, tail)
+		return
+	}
+	c.link(&c.head, first, tail)
+}
+
+// link push a list of chunks from the chunk of index first to tail onto the free list of head.
+func (c *class) link(head *uint64, first int, tail *chunk) {
+	// retag the head so the goroutines still holding the old head fail their CAS.
+	chk := c.chunkAt(first)
+	chk.aba++
+	new := uint64(first+1)<<32 + uint64(chk.aba)
+	for {
+		old := atomic.LoadUint64(head)
+		atomic.StoreUint64(&tail.next, old)
+		if atomic.CompareAndSwapUint64(head, old, new) {
+			break
+		}
+		runtime.Gosched()
+	}
+}
+
+// pointerOf returns the address of mem's underlying array, it works on zero length slice too.
+func pointerOf(mem []byte) uintptr {
+	return (*reflect.SliceHeader)(unsafe.Pointer(&mem)).Data
+}
+
+// zeroFree zero a chunk on the free list, it's unpoisoned for the sanitizers in the meantime.
+func zeroFree(mem []byte) {
+	sanitizeAlloc(mem)
+	zero(mem)
+	sanitizeFree(mem)
+}
+
+func zero(mem []byte) {
+	for i := range mem {
+		mem[i] = 0
+	}
+}
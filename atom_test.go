@@ -1,7 +1,11 @@
 package slab
 
 import (
+	"reflect"
+	"runtime"
+	"sync"
 	"testing"
+	"unsafe"
 
 	"github.com/funny/utest"
 )
@@ -25,6 +29,300 @@ func Test_AtomPool_AllocAndFree(t *testing.T) {
 	}
 }
 
+func Test_AtomPool_PageRange(t *testing.T) {
+	pool := NewAtomPool(64, 64*1024, 2, 64*1024)
+	utest.EqualNow(t, len(pool.classes), 11)
+	utest.EqualNow(t, cap(pool.classes), 11)
+	for i := 0; i < len(pool.classes); i++ {
+		c := &pool.classes[i]
+		last := &c.chunks[len(c.chunks)-1]
+		utest.EqualNow(t, c.pageBegin, uintptr(unsafe.Pointer(&c.page[0])))
+		utest.EqualNow(t, c.pageEnd, uintptr(unsafe.Pointer(&last.mem[0])))
+		utest.EqualNow(t, c.pageEnd-c.pageBegin, uintptr(len(c.page)-c.size))
+	}
+}
+
+func Test_AtomPool_UnalignedMaxSize(t *testing.T) {
+	pool := NewAtomPool(64, 1000, 2, 1024)
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{64, 128, 256, 512, 1000})
+	for _, size := range []int{513, 777, 1000} {
+		mem := pool.Alloc(size)
+		utest.EqualNow(t, len(mem), size)
+		utest.EqualNow(t, cap(mem), 1000)
+		pool.Free(mem)
+	}
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(0))
+
+	// maxSize doesn't fit in a page, the classes stop at the page.
+	pool = NewAtomPool(64, 1000, 2, 900)
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{64, 128, 256, 512})
+
+	// maxSize is on the way, no extra class.
+	pool = NewAtomPool(64, 1024, 2, 1024)
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{64, 128, 256, 512, 1024})
+
+	pool = NewAtomPool(100, 1000, 3, 1000)
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{100, 300, 900, 1000})
+}
+
+func Test_AtomPool_SizeIndex(t *testing.T) {
+	for _, pool := range []*AtomPool{
+		NewAtomPool(128, 64*1024, 2, 1024*1024),
+		NewAtomPool(64, 1000, 3, 1024),
+		NewAtomPool(100, 1000*1000, 10, 1000*1000),
+	} {
+		utest.NotNilNow(t, pool.sizeIndex)
+		for size := 0; size <= pool.maxSize+1; size++ {
+			expect := -1
+			for i := 0; i < len(pool.classes) && size <= pool.maxSize; i++ {
+				if pool.classes[i].size >= size {
+					expect = i
+					break
+				}
+			}
+			utest.EqualNow(t, pool.classForAlloc(size), expect)
+		}
+	}
+}
+
+func Test_AtomPool_AllocFreeRoundTrip(t *testing.T) {
+	fibonacci := func() func(int) int {
+		prev := 64
+		return func(size int) int {
+			next := size + prev
+			prev = size
+			return next
+		}
+	}
+	for _, pool := range []*AtomPool{
+		NewAtomPool(128, 64*1024, 2, 1024*1024),
+		NewAtomPool(64, 1000, 2, 1024),
+		NewAtomPool(64, 1000, 3, 1024, WithLargerClasses(2)),
+		NewAtomPool(128, 4096, 2, 4096, WithMaxWaste(0.25)),
+		NewAtomPoolN(100, 1000, 7, 1000),
+		NewAtomPoolFunc(64, 1000, 1024, fibonacci()),
+	} {
+		for size := 0; size <= pool.maxSize+1; size++ {
+			mem := pool.Alloc(size)
+			utest.EqualNow(t, len(mem), size)
+			pooled := pool.classForPointer(pointerOf(mem)) >= 0
+			utest.EqualNow(t, pooled, pool.classForAlloc(size) >= 0)
+			utest.EqualNow(t, pool.WouldAccept(mem), pooled)
+			if pooled {
+				utest.EqualNow(t, pool.classForFree(cap(mem)), pool.classForPointer(pointerOf(mem)))
+			}
+			pool.Free(mem)
+			utest.EqualNow(t, pool.Stats().InUse, 0)
+		}
+		utest.IsNilNow(t, pool.CheckInvariants())
+	}
+
+	unsafePool := NewUnsafePool(64, 1000, 2, 1024)
+	for size := 0; size <= unsafePool.maxSize+1; size++ {
+		mem := unsafePool.Alloc(size)
+		utest.EqualNow(t, len(mem), size)
+		unsafePool.Free(mem)
+		if size <= unsafePool.maxSize {
+			// the chunk is on top of the free list again.
+			utest.EqualNow(t, pointerOf(unsafePool.Alloc(size)), pointerOf(mem))
+			unsafePool.Free(mem)
+		}
+	}
+}
+
+func Test_AtomPoolN(t *testing.T) {
+	pool := NewAtomPoolN(64, 64*1024, 11, 1024*1024)
+	utest.EqualNow(t, len(pool.classes), 11)
+	for i := 0; i < len(pool.classes); i++ {
+		utest.EqualNow(t, pool.classes[i].size, 64<<uint(i))
+	}
+
+	pool = NewAtomPoolN(100, 1000, 4, 1000)
+	sizes := []int{100, 215, 464, 1000}
+	utest.EqualNow(t, len(pool.classes), len(sizes))
+	for i := 0; i < len(sizes); i++ {
+		utest.EqualNow(t, pool.classes[i].size, sizes[i])
+	}
+	mem := pool.Alloc(900)
+	utest.EqualNow(t, cap(mem), 1000)
+
+	pool = NewAtomPoolN(64, 1024, 1, 1024)
+	utest.EqualNow(t, len(pool.classes), 1)
+	utest.EqualNow(t, pool.classes[0].size, 1024)
+
+	pool = NewAtomPoolN(64, 67, 4, 1024)
+	sizes = []int{64, 65, 66, 67}
+	for i := 0; i < len(sizes); i++ {
+		utest.EqualNow(t, pool.classes[i].size, sizes[i])
+	}
+
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	NewAtomPoolN(64, 1024, 0, 1024)
+}
+
+func Test_AtomPoolFunc(t *testing.T) {
+	prev := 64
+	fibonacci := func(size int) int {
+		next := size + prev
+		prev = size
+		return next
+	}
+	pool := NewAtomPoolFunc(64, 1000, 1024, fibonacci)
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{64, 128, 192, 320, 512, 832, 1000})
+	mem := pool.Alloc(900)
+	utest.EqualNow(t, cap(mem), 1000)
+	pool.Free(mem)
+
+	pool = NewAtomPoolFunc(100, 1000, 500, func(size int) int { return size + 100 })
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{100, 200, 300, 400, 500})
+
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	NewAtomPoolFunc(64, 1024, 1024, func(size int) int { return size })
+}
+
+func Test_AtomPool_CapacityOf(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(200)
+	utest.EqualNow(t, pool.CapacityOf(mem), 256)
+	utest.EqualNow(t, pool.CapacityOf(mem[:10:10]), 256)
+	utest.EqualNow(t, pool.CapacityOf(pool.Alloc(2048)), -1)
+	utest.EqualNow(t, pool.CapacityOf(make([]byte, 128)), -1)
+	utest.EqualNow(t, pool.CapacityOf(nil), -1)
+}
+
+func Test_AtomPool_Dup(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	src := []byte("hello world")
+	mem := pool.Dup(src)
+	utest.EqualNow(t, string(mem), "hello world")
+	utest.EqualNow(t, cap(mem), 128)
+	src[0] = 'H'
+	utest.EqualNow(t, string(mem), "hello world")
+	pool.Free(mem)
+
+	src = make([]byte, 2048)
+	src[2047] = 1
+	mem = pool.Dup(src)
+	utest.EqualNow(t, len(mem), 2048)
+	utest.EqualNow(t, mem[2047], byte(1))
+	utest.EqualNow(t, len(pool.Dup(nil)), 0)
+}
+
+func Test_AtomPool_AllocAny(t *testing.T) {
+	pool := NewAtomPool(128, 512, 2, 512)
+	sizes := []int{}
+	for {
+		mem, size := pool.AllocAny()
+		if mem == nil {
+			utest.EqualNow(t, size, 0)
+			break
+		}
+		utest.EqualNow(t, len(mem), size)
+		sizes = append(sizes, size)
+	}
+	utest.DeepEqualNow(t, sizes, []int{512, 256, 256, 128, 128, 128, 128})
+	utest.EqualNow(t, pool.Stats().InUse, 512*3)
+}
+
+func Test_AtomPool_AllocInto(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	var mem []byte
+	pool.AllocInto(&mem, 100)
+	utest.EqualNow(t, len(mem), 100)
+	utest.EqualNow(t, cap(mem), 128)
+	pool.Free(mem)
+	pool.AllocInto(&mem, 2048)
+	utest.EqualNow(t, len(mem), 2048)
+}
+
+func Test_AtomPool_Realloc(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Realloc(nil, 100)
+	utest.EqualNow(t, cap(mem), 128)
+	copy(mem, "frame")
+
+	same := pool.Realloc(mem, 120)
+	utest.EqualNow(t, len(same), 120)
+	utest.EqualNow(t, pointerOf(same), pointerOf(mem))
+
+	grown := pool.Realloc(same, 300)
+	utest.EqualNow(t, len(grown), 300)
+	utest.EqualNow(t, cap(grown), 512)
+	utest.EqualNow(t, string(grown[:5]), "frame")
+	utest.EqualNow(t, pool.Stats().InUse, 512)
+
+	big := pool.Realloc(grown, 2000)
+	utest.EqualNow(t, string(big[:5]), "frame")
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_With(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	pool.With(100, func(mem []byte) {
+		utest.EqualNow(t, len(mem), 100)
+		utest.EqualNow(t, cap(mem), 128)
+		utest.EqualNow(t, pool.Stats().InUse, 128)
+	})
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+
+	func() {
+		defer func() {
+			utest.EqualNow(t, recover(), "oops")
+		}()
+		pool.With(100, func(mem []byte) {
+			panic("oops")
+		})
+	}()
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_FreeAcross(t *testing.T) {
+	pools := []*AtomPool{
+		NewAtomPool(128, 1024, 2, 1024),
+		NewAtomPool(128, 1024, 2, 1024),
+	}
+	mem := pools[1].Alloc(100)
+	utest.Assert(t, FreeAcross(pools, mem))
+	utest.EqualNow(t, pools[1].Stats().InUse, 0)
+	utest.Assert(t, !FreeAcross(pools, make([]byte, 128)))
+	utest.Assert(t, !FreeAcross(pools[:1], pools[1].Alloc(100)))
+	utest.IsNilNow(t, pools[0].CheckInvariants())
+	utest.IsNilNow(t, pools[1].CheckInvariants())
+}
+
+func Test_AtomPool_ClassSizes(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 2048)
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{128, 256, 512, 1024})
+	utest.DeepEqualNow(t, pool.ChunkCounts(), []int{16, 8, 4, 2})
+}
+
+func Test_AtomPoolOn(t *testing.T) {
+	arena := make([]byte, 4*1024+100)
+	pool, err := NewAtomPoolOn(arena, 128, 1024, 2)
+	utest.IsNilNow(t, err)
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{128, 256, 512, 1024})
+	utest.DeepEqualNow(t, pool.ChunkCounts(), []int{8, 4, 2, 1})
+
+	mem := pool.Alloc(1000)
+	utest.EqualNow(t, cap(mem), 1024)
+	ptr := pointerOf(mem)
+	utest.Assert(t, ptr >= uintptr(unsafe.Pointer(&arena[0])))
+	utest.Assert(t, ptr < uintptr(unsafe.Pointer(&arena[len(arena)-1])))
+	pool.Free(mem)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	_, err = NewAtomPoolOn(make([]byte, 4*1024-1), 128, 1024, 2)
+	utest.NotNilNow(t, err)
+	_, err = NewAtomPoolOn(arena, 128, 1024, 1)
+	utest.NotNilNow(t, err)
+}
+
 func Test_AtomPool_AllocSmall(t *testing.T) {
 	pool := NewAtomPool(128, 1024, 2, 1024)
 	mem := pool.Alloc(64)
@@ -42,6 +340,9 @@ func Test_AtomPool_AllocLarge(t *testing.T) {
 }
 
 func Test_AtomPool_DoubleFree(t *testing.T) {
+	if !chunkChecks {
+		t.Skip("the chunk checks are stripped by slab_unsafe")
+	}
 	pool := NewAtomPool(128, 1024, 2, 1024)
 	mem := pool.Alloc(64)
 	go func() {
@@ -53,6 +354,87 @@ func Test_AtomPool_DoubleFree(t *testing.T) {
 	}()
 }
 
+func Test_AtomPool_FreeForeign(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(128)
+	pool.Free(make([]byte, 128))
+	pool.Free(make([]byte, 64, 1024))
+
+	for i := 0; i < len(pool.classes); i++ {
+		n := 0
+		for pool.classes[i].Pop() != nil {
+			n++
+		}
+		if i == 0 {
+			n++
+		}
+		utest.EqualNow(t, n, len(pool.classes[i].chunks))
+	}
+	pool.Free(mem)
+}
+
+func Test_AtomPool_FreeZeroLength(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(0)
+	utest.EqualNow(t, len(mem), 0)
+	utest.EqualNow(t, cap(mem), 128)
+	pool.Free(mem)
+
+	mem = pool.Alloc(1024)
+	utest.Assert(t, pool.classes[3].head == 0)
+	pool.Free(mem[:0])
+	utest.Assert(t, pool.classes[3].head != 0)
+
+	mem = pool.Alloc(1024)
+	utest.EqualNow(t, len(mem), 1024)
+	utest.EqualNow(t, cap(mem), 1024)
+
+	pool.Free(make([]byte, 0))
+	pool.Free(nil)
+}
+
+func Test_AtomPool_FreeCap(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(1000)
+	utest.Assert(t, pool.classes[3].head == 0)
+
+	pool.Free(mem[:10:10])
+	utest.Assert(t, pool.classes[3].head != 0)
+
+	mem = pool.Alloc(1000)
+	utest.Assert(t, pool.classes[3].head == 0)
+	pool.FreeCap(mem[:10:10], 1024)
+	utest.Assert(t, pool.classes[3].head != 0)
+
+	pool.FreeCap(make([]byte, 10), 1024)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_WouldAccept(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(100)
+	utest.Assert(t, pool.WouldAccept(mem))
+	utest.Assert(t, pool.WouldAccept(mem[:0]))
+	utest.Assert(t, !pool.WouldAccept(mem[1:]))
+	utest.Assert(t, !pool.WouldAccept(make([]byte, 128)))
+	utest.Assert(t, !pool.WouldAccept(make([]byte, 2048)))
+	utest.Assert(t, !pool.WouldAccept(pool.classes[0].page[64:192:192]))
+
+	pool.Free(mem)
+	utest.Assert(t, !pool.WouldAccept(mem))
+}
+
+func Test_AtomPool_BadChunk(t *testing.T) {
+	if !chunkChecks {
+		t.Skip("the chunk checks are stripped by slab_unsafe")
+	}
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	pool.Free(pool.classes[0].page[64:192:192])
+}
+
 func Test_AtomPool_AllocSlow(t *testing.T) {
 	pool := NewAtomPool(128, 1024, 2, 1024)
 	mem := pool.classes[len(pool.classes)-1].Pop()
@@ -63,6 +445,161 @@ func Test_AtomPool_AllocSlow(t *testing.T) {
 	utest.EqualNow(t, cap(mem), 1024)
 }
 
+func Test_AtomPool_AllocAtLeast(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem, capacity := pool.AllocAtLeast(300)
+	utest.EqualNow(t, len(mem), 300)
+	utest.EqualNow(t, capacity, 512)
+	mem = mem[:capacity]
+	pool.Free(mem)
+
+	mem, capacity = pool.AllocAtLeast(2000)
+	utest.EqualNow(t, len(mem), 2000)
+	utest.EqualNow(t, capacity, 2000)
+}
+
+func Test_AtomPool_MaxOversize(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	utest.EqualNow(t, pool.MaxOversize(), 0)
+	pool.Alloc(1024)
+	pool.Alloc(1024)
+	utest.EqualNow(t, pool.MaxOversize(), 0)
+	pool.Alloc(4096)
+	pool.Alloc(2048)
+	utest.EqualNow(t, pool.MaxOversize(), 4096)
+	pool.AllocPair(16, 8192)
+	utest.EqualNow(t, pool.MaxOversize(), 8192)
+}
+
+func Test_AtomPool_AllocPair(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	header, payload := pool.AllocPair(16, 1000)
+	utest.EqualNow(t, len(header), 16)
+	utest.EqualNow(t, cap(header), 128)
+	utest.EqualNow(t, len(payload), 1000)
+	utest.EqualNow(t, cap(payload), 1024)
+
+	// the only 1024 chunk is in use, so both should fallback.
+	header2, payload2 := pool.AllocPair(16, 1000)
+	utest.EqualNow(t, cap(header2), 16)
+	utest.EqualNow(t, cap(payload2), 1000)
+
+	pool.FreePair(header, payload)
+	utest.Assert(t, pool.classes[0].head != 0)
+	utest.Assert(t, pool.classes[len(pool.classes)-1].head != 0)
+
+	header, payload = pool.AllocPair(16, 2048)
+	utest.EqualNow(t, cap(header), 16)
+	utest.EqualNow(t, cap(payload), 2048)
+}
+
+func Test_AtomPool_Clear(t *testing.T) {
+	if asanEnabled {
+		t.Skip("read the freed chunk")
+	}
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem1 := pool.Alloc(128)
+	mem2 := pool.Alloc(128)
+	for i := range mem1 {
+		mem1[i] = 0xFF
+		mem2[i] = 0xFF
+	}
+	pool.Free(mem1)
+	pool.Clear()
+
+	for _, b := range mem1 {
+		utest.EqualNow(t, b, byte(0))
+	}
+	for _, b := range mem2 {
+		utest.EqualNow(t, b, byte(0xFF))
+	}
+
+	n := 0
+	for pool.classes[0].Pop() != nil {
+		n++
+	}
+	utest.EqualNow(t, n, len(pool.classes[0].chunks)-1)
+}
+
+func Test_AtomPool_ClearConcurrent(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10000; j++ {
+				mem := pool.Alloc(256)
+				mem[0] = 1
+				pool.Free(mem)
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		pool.Clear()
+	}
+	wg.Wait()
+
+	n := 0
+	for pool.classes[1].Pop() != nil {
+		n++
+	}
+	utest.EqualNow(t, n, len(pool.classes[1].chunks))
+}
+
+// Test_AtomPool_Race stress the CAS of the free list heads, CI runs it with GOARCH=386 too, where -race is not available.
+func Test_AtomPool_Race(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	for _, options := range [][]Option{nil, {WithShards(4)}, {WithMaxPages(4)}} {
+		pool := NewAtomPool(128, 1024, 2, 2048, options...)
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				mems := make([][]byte, 0, 8)
+				for i := 0; i < 5000; i++ {
+					mem := pool.Alloc(128)
+					mem[0] = byte(g)
+					mems = append(mems, mem)
+					if len(mems) == cap(mems) || i%(g+2) == 0 {
+						for _, mem := range mems {
+							utest.Equal(t, mem[0], byte(g))
+							pool.Free(mem)
+						}
+						mems = mems[:0]
+					}
+				}
+				for _, mem := range mems {
+					pool.Free(mem)
+				}
+			}(g)
+		}
+		wg.Wait()
+		utest.EqualNow(t, pool.Stats().InUse, 0)
+		utest.IsNilNow(t, pool.CheckInvariants())
+	}
+}
+
+// Test_Alignment check the 64-bit fields of atomic operations are 64-bit aligned on the 32-bit platforms too,
+// run it with GOARCH=386 or arm. The types of slice elements must have a size of multiple of 8.
+func Test_Alignment(t *testing.T) {
+	for _, v := range []interface{}{AtomPool{}, class{}, chunk{}, shard{}, fifo{}, fifoSlot{}, limiter{}} {
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if k := f.Type.Kind(); (k == reflect.Uint64 || k == reflect.Int64) && f.Offset%8 != 0 {
+				t.Fatalf("%s.%s is at offset %d", typ.Name(), f.Name, f.Offset)
+			}
+		}
+	}
+	for _, v := range []interface{}{class{}, chunk{}, shard{}, fifoSlot{}} {
+		if size := reflect.TypeOf(v).Size(); size%8 != 0 {
+			t.Fatalf("size of %s is %d", reflect.TypeOf(v).Name(), size)
+		}
+	}
+}
+
 func Benchmark_AtomPool_AllocAndFree_128(b *testing.B) {
 	pool := NewAtomPool(128, 1024, 2, 64*1024)
 	b.ResetTimer()
@@ -92,3 +629,130 @@ func Benchmark_AtomPool_AllocAndFree_512(b *testing.B) {
 		}
 	})
 }
+
+func Benchmark_AtomPool_AllocAndFree_Burst(b *testing.B) {
+	pool := NewAtomPool(128, 1024, 2, 1024*1024)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		temp := make([][]byte, 256)
+		for pb.Next() {
+			for i := 0; i < len(temp); i++ {
+				temp[i] = pool.Alloc(128)
+			}
+			for i := 0; i < len(temp); i++ {
+				pool.Free(temp[i])
+			}
+		}
+	})
+}
+
+func Benchmark_AtomPool_AllocAndFree_64K(b *testing.B) {
+	pool := NewAtomPool(64, 64*1024, 2, 1024*1024)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Free(pool.Alloc(64 * 1024))
+		}
+	})
+}
+
+func Benchmark_AtomPool_Exhausted(b *testing.B) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024)
+	for pool.classes[0].Pop() != nil {
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Free(pool.Alloc(128))
+		}
+	})
+}
+
+func Benchmark_AtomPool_Exhausted_LargerClasses(b *testing.B) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024, WithLargerClasses(1))
+	for pool.classes[0].Pop() != nil {
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Free(pool.Alloc(128))
+		}
+	})
+}
+
+func Benchmark_AtomPool_Free_SameClass(b *testing.B) {
+	pool := NewAtomPoolN(64, 64*1024, 64, 1024*1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.Free(pool.Alloc(64 * 1024))
+	}
+}
+
+// Benchmark_AtomPool_AllocAndFree_Serial measures the single goroutine cost of Free,
+// compare it with -tags slab_unsafe to see what the chunk checks cost.
+func Benchmark_AtomPool_AllocAndFree_Serial(b *testing.B) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.Free(pool.Alloc(128))
+	}
+}
+
+type benchmarkConn struct {
+	buf []byte
+}
+
+func Benchmark_AtomPool_Alloc_Field(b *testing.B) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024)
+	conn := &benchmarkConn{}
+	for i := 0; i < b.N; i++ {
+		conn.buf = pool.Alloc(128)
+		pool.Free(conn.buf)
+	}
+}
+
+func Benchmark_AtomPool_AllocInto_Field(b *testing.B) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024)
+	conn := &benchmarkConn{}
+	for i := 0; i < b.N; i++ {
+		pool.AllocInto(&conn.buf, 128)
+		pool.Free(conn.buf)
+	}
+}
+
+func Test_ClassSizes_Overflow(t *testing.T) {
+	maxInt := int(^uint(0) >> 1)
+	minSize := maxInt/4 + 1
+	utest.DeepEqualNow(t, classSizes(minSize, maxInt, 3, maxInt), []int{minSize, minSize * 3, maxInt})
+	utest.DeepEqualNow(t, classSizes(maxInt, maxInt, 2, maxInt), []int{maxInt})
+	utest.EqualNow(t, growSize(maxInt/2, 2), maxInt-1)
+	utest.EqualNow(t, growSize(maxInt/2+1, 2), 0)
+
+	pool := NewSyncPool(minSize, maxInt, 3)
+	utest.DeepEqualNow(t, pool.classesSize, []int{minSize, minSize * 3})
+}
+
+func Test_AtomPool_Owns(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(100)
+	utest.Assert(t, pool.Owns(mem))
+	utest.Assert(t, pool.Owns(mem[10:20]))
+	utest.Assert(t, !pool.Owns(make([]byte, 128)))
+	utest.Assert(t, !pool.Owns(nil))
+	utest.Assert(t, !pool.Owns(pool.Alloc(2000)))
+
+	// a heap []byte of the class size is not released.
+	utest.Assert(t, !pool.Recycle(make([]byte, 128)))
+	utest.Assert(t, pool.Recycle(mem[:5:5]))
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_StrictFree(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithStrictFree())
+	pool.Free(pool.Alloc(100))
+	pool.Free(nil)
+	defer func() {
+		utest.EqualNow(t, recover(), "slab.AtomPool: Foreign Free")
+	}()
+	pool.Free(make([]byte, 128))
+}
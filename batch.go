@@ -0,0 +1,142 @@
+package slab
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// AllocBatch alloc n []byte of size at once and append them to a new [][]byte.
+// The chunks are taken off the free list of the class as a segment by one CAS, instead of one CAS for each chunk,
+// and the rest are alloc by Alloc when the class has less than n free chunks.
+// The classes of WithFIFO or WithRateLimit, and the pools of WithAllocInterceptor, alloc each []byte by Alloc.
+// A []byte can be nil for a pool of WithStrict. Release them by FreeBatch or each by Free.
+func (pool *AtomPool) AllocBatch(size, n int) [][]byte {
+	mems := make([][]byte, 0, n)
+	if i := pool.classForAlloc(size); i >= 0 && pool.interceptor == nil {
+		if c := &pool.classes[i]; c.fifo == nil && c.limit == nil {
+			if c.shards != nil {
+				start := shardHint(len(c.shards))
+				for k := 0; k < len(c.shards) && len(mems) < n; k++ {
+					s := &c.shards[(start+k)%len(c.shards)]
+					mems = c.popSegment(&s.head, &s.pops, mems, n-len(mems))
+				}
+			}
+			if len(mems) < n {
+				mems = c.popSegment(&c.head, &c.pops, mems, n-len(mems))
+			}
+			for k, mem := range mems {
+				mems[k] = pool.poison(mem)[:size]
+				if pool.logger != nil {
+					pool.logAlloc(size, c, true)
+				}
+				if pool.leakDetection {
+					pool.recordAlloc(mem)
+				}
+			}
+		}
+	}
+	for len(mems) < n {
+		mems = append(mems, pool.Alloc(size))
+	}
+	return mems
+}
+
+// popSegment take up to n chunks off the free list of head by one CAS and append them to dst.
+// A free list changes only at its head and the head is tagged, so the segment walked is not changed
+// if the head is not changed when the CAS succeeds. The walk may read the chunks taken off the list in the meantime,
+// so it stops at a next that links no chunk.
+func (c *class) popSegment(head, pops *uint64, dst [][]byte, n int) [][]byte {
+	for spins := 0; ; {
+		old := atomic.LoadUint64(head)
+		if old == 0 {
+			return dst
+		}
+		k, nxt := 0, old
+		for ; nxt>>32 != 0 && k < n; k++ {
+			nxt = atomic.LoadUint64(&c.chunkAt(int(nxt>>32 - 1)).next)
+		}
+		// a next other than 0 that links no chunk is read from a chunk taken off the list, the head is changed then.
+		if nxt == 0 || nxt>>32 != 0 {
+			if c.casStats {
+				atomic.AddUint64(&c.casAttempts, 1)
+			}
+			if atomic.CompareAndSwapUint64(head, old, nxt) {
+				for i, j := old, 0; j < k; j++ {
+					chk := c.chunkAt(int(i>>32 - 1))
+					i = atomic.LoadUint64(&chk.next)
+					atomic.StoreUint64(&chk.next, 0)
+					sanitizeAlloc(chk.mem)
+					dst = append(dst, chk.mem)
+				}
+				atomic.AddUint64(pops, uint64(k))
+				if c.casStats {
+					atomic.AddUint64(&c.casSuccesses, 1)
+				}
+				return dst
+			}
+		}
+		if spins < c.spins {
+			spins++
+		} else {
+			runtime.Gosched()
+		}
+	}
+}
+
+// batchPending marks chunk.next of the last chunk of a chain until FreeBatch links the chain into the free list,
+// so the chunk is still a double free if mems has it twice.
+const batchPending = 1
+
+// FreeBatch release the [][]byte alloc from the pool at once, e.g. by AllocBatch.
+// The chunks of a class are chained and linked into its free list by one CAS, instead of one CAS for each chunk,
+// the other []byte are released each by Free, such as the ones from make() or resliced to a smaller capacity.
+// The bad chunk and double free panic as Free, the chunks before it in mems are still released.
+// The classes of WithFIFO, and the pools of WithLogger, WithLeakDetection or AllocContext, free each []byte by Free.
+func (pool *AtomPool) FreeBatch(mems [][]byte) {
+	if pool.fifo || pool.logger != nil || pool.leakDetection || atomic.LoadInt32(&pool.tracked) > 0 {
+		for _, mem := range mems {
+			pool.Free(mem)
+		}
+		return
+	}
+	// the first chunk and the last chunk of the chain of each class.
+	firsts := make([]int, len(pool.classes))
+	tails := make([]*chunk, len(pool.classes))
+	counts := make([]uint64, len(pool.classes))
+	defer func() {
+		// link the chains even when a bad chunk or a double free panics, so the chunks before it are not lost.
+		for i := range pool.classes {
+			if tails[i] != nil {
+				c := &pool.classes[i]
+				c.attach(firsts[i], tails[i])
+				atomic.AddUint64(&c.pushes, counts[i])
+			}
+		}
+	}()
+	for _, mem := range mems {
+		i := pool.classForFree(cap(mem))
+		if i < 0 {
+			pool.free(mem, cap(mem))
+			continue
+		}
+		c := &pool.classes[i]
+		ptr := pointerOf(mem)
+		k := c.chunkIndex(ptr)
+		if k < 0 {
+			// a []byte resliced to the capacity of another class is found by the address.
+			pool.free(mem, cap(mem))
+			continue
+		}
+		chk := c.chunkAt(k)
+		c.release(chk, ptr)
+		chk.aba++
+		if tails[i] == nil {
+			tails[i] = chk
+			atomic.StoreUint64(&chk.next, batchPending)
+		} else {
+			atomic.StoreUint64(&chk.next, uint64(firsts[i]+1)<<32+uint64(c.chunkAt(firsts[i]).aba))
+		}
+		firsts[i] = k
+		counts[i]++
+	}
+}
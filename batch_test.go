@@ -0,0 +1,137 @@
+package slab
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AllocBatch(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithCASStats())
+	mems := pool.AllocBatch(100, 10)
+	utest.EqualNow(t, len(mems), 10)
+	pooled := 0
+	for _, mem := range mems {
+		utest.EqualNow(t, len(mem), 100)
+		if pool.classForPointer(pointerOf(mem)) >= 0 {
+			pooled++
+		}
+	}
+	utest.EqualNow(t, pooled, 8)
+	stats := pool.Stats().Classes[0]
+	utest.EqualNow(t, stats.Free, 0)
+	utest.EqualNow(t, stats.CASSuccesses, uint64(1))
+
+	pool.FreeBatch(mems)
+	stats = pool.Stats().Classes[0]
+	utest.EqualNow(t, stats.Free, 8)
+	utest.EqualNow(t, stats.Frees, uint64(8))
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	// the chunks of many classes.
+	mems = append(pool.AllocBatch(100, 3), pool.AllocBatch(1000, 2)...)
+	mems = append(mems, pool.Alloc(300), pool.Alloc(5000))
+	pool.FreeBatch(mems)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	utest.EqualNow(t, len(pool.AllocBatch(5000, 2)), 2)
+}
+
+func Test_FreeBatch_DoubleFree(t *testing.T) {
+	if !chunkChecks {
+		t.Skip("chunk checks are disabled by the slab_unsafe build tag")
+	}
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mems := pool.AllocBatch(128, 2)
+	defer func() {
+		utest.NotNilNow(t, recover())
+		// the chunks before the double free are released.
+		utest.EqualNow(t, pool.Stats().InUse, 0)
+		utest.IsNilNow(t, pool.CheckInvariants())
+	}()
+	pool.FreeBatch([][]byte{mems[0], mems[1], mems[1]})
+}
+
+func Test_FreeBatch_DoubleFirst(t *testing.T) {
+	if !chunkChecks {
+		t.Skip("chunk checks are disabled by the slab_unsafe build tag")
+	}
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(128)
+	defer func() {
+		utest.EqualNow(t, recover(), "slab.AtomPool: Double Free")
+	}()
+	pool.FreeBatch([][]byte{mem, mem})
+}
+
+func Test_FreeBatch_Resliced(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 4096)
+	mems := pool.AllocBatch(1000, 2)
+	mems[0] = mems[0][:128:128]
+	mems = append(mems, pool.Alloc(128))
+	pool.FreeBatch(mems)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	strict := NewAtomPool(128, 1024, 2, 4096, WithStrictFree())
+	func() {
+		defer func() {
+			utest.EqualNow(t, recover(), "slab.AtomPool: Foreign Free")
+		}()
+		strict.FreeBatch([][]byte{make([]byte, 128)})
+	}()
+}
+
+func Test_AllocBatch_Concurrent(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 8192, WithShards(2))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				mems := pool.AllocBatch(128, 16)
+				pool.FreeBatch(mems)
+				pool.Free(pool.Alloc(128))
+			}
+		}()
+	}
+	wg.Wait()
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AllocBatch_FreeBatch_Race(t *testing.T) {
+	// the goroutines must run in parallel to interleave in the walk of a segment, CI runs it with GOARCH=386 too.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	for _, shards := range []int{1, 4} {
+		// a few chunks, so the segments taken by AllocBatch overlap the chains linked by FreeBatch.
+		pool := NewAtomPool(128, 1024, 2, 2048, WithShards(shards))
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 2000; i++ {
+					// the []byte made by make() keep the chain of FreeBatch pending for longer.
+					pool.FreeBatch(pool.AllocBatch(128, 1+(g+i)%24))
+				}
+			}(g)
+		}
+		wg.Wait()
+		utest.EqualNow(t, pool.Stats().InUse, 0)
+		utest.EqualNow(t, pool.Stats().Classes[0].Free, 16)
+		utest.IsNilNow(t, pool.CheckInvariants())
+	}
+}
+
+func Benchmark_AtomPool_AllocBatch_64(b *testing.B) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.FreeBatch(pool.AllocBatch(128, 64))
+	}
+}
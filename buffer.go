@@ -0,0 +1,234 @@
+package slab
+
+import (
+	"errors"
+	"io"
+)
+
+// minRead is the free space ensured by Buffer.ReadFrom before each Read, as bytes.Buffer.
+const minRead = 512
+
+// ErrTooLarge is the panic of Buffer when the size it has to grow to overflows int.
+var ErrTooLarge = errors.New("slab.Buffer: too large")
+
+// Buffer is a variable sized buffer of bytes on a pooled []byte, as bytes.Buffer.
+// When it's full the data is copied to a []byte of at least twice the capacity and the old one is released.
+// Reset or Close release the []byte into the pool, so the slices returned by Bytes are invalid after that.
+// A Buffer of a pool of WithStrict panics with ErrExhausted when the pool can't alloc the size it has to grow to.
+// The zero Buffer is not usable, create one by NewBuffer or AtomPool.Buffer.
+// It's not safe for concurrent use.
+type Buffer struct {
+	pool Pool
+	buf  []byte
+	off  int // read at buf[off], write at buf[len(buf)]
+}
+
+var (
+	_ io.ReadWriteCloser = (*Buffer)(nil)
+	_ io.ByteScanner     = (*Buffer)(nil)
+	_ io.ByteWriter      = (*Buffer)(nil)
+	_ io.ReaderFrom      = (*Buffer)(nil)
+	_ io.WriterTo        = (*Buffer)(nil)
+)
+
+// NewBuffer create an empty Buffer alloc from pool, size is the initial capacity and it can be 0.
+func NewBuffer(pool Pool, size int) *Buffer {
+	b := &Buffer{pool: pool}
+	if size > 0 {
+		if b.buf = pool.Alloc(size); b.buf == nil {
+			panic(ErrExhausted)
+		}
+		b.buf = b.buf[:0]
+	}
+	return b
+}
+
+// Buffer create an empty Buffer alloc from the pool, see NewBuffer.
+func (pool *AtomPool) Buffer(size int) *Buffer {
+	return NewBuffer(pool, size)
+}
+
+// Bytes returns the unread bytes, it's valid until the next write, Reset or Close.
+func (b *Buffer) Bytes() []byte {
+	return b.buf[b.off:]
+}
+
+// String returns the unread bytes as a string.
+func (b *Buffer) String() string {
+	return string(b.buf[b.off:])
+}
+
+// Len returns the number of the unread bytes.
+func (b *Buffer) Len() int {
+	return len(b.buf) - b.off
+}
+
+// Cap returns the capacity of the pooled []byte.
+func (b *Buffer) Cap() int {
+	return cap(b.buf)
+}
+
+// Grow make sure there is space for another n bytes, without another grow.
+func (b *Buffer) Grow(n int) {
+	if n < 0 {
+		panic("slab.Buffer: negative count")
+	}
+	b.grow(n)
+}
+
+// grow make space for n bytes and returns the index to write them.
+func (b *Buffer) grow(n int) int {
+	m := b.Len()
+	if m == 0 && b.off != 0 {
+		b.buf = b.buf[:0]
+		b.off = 0
+	}
+	if n <= cap(b.buf)-len(b.buf) {
+		return len(b.buf)
+	}
+	if m+n < 0 {
+		panic(ErrTooLarge)
+	}
+	if m+n <= cap(b.buf) {
+		// there is enough space after the read bytes are dropped.
+		copy(b.buf, b.buf[b.off:])
+	} else {
+		size := m + n
+		if c := 2 * cap(b.buf); c > size {
+			// grow geometrically, so a Buffer larger than the pooled sizes isn't copied by every write.
+			size = c
+		}
+		mem := b.pool.Alloc(size)
+		if mem == nil && size > m+n {
+			mem = b.pool.Alloc(m + n)
+		}
+		if mem == nil {
+			panic(ErrExhausted)
+		}
+		copy(mem, b.buf[b.off:])
+		if b.buf != nil {
+			b.pool.Free(b.buf)
+		}
+		b.buf = mem
+	}
+	b.buf = b.buf[:m]
+	b.off = 0
+	return m
+}
+
+// Write implements io.Writer, the error is always nil.
+func (b *Buffer) Write(p []byte) (int, error) {
+	m := b.grow(len(p))
+	b.buf = b.buf[:m+len(p)]
+	return copy(b.buf[m:], p), nil
+}
+
+// WriteString appends s to the buffer, the error is always nil.
+func (b *Buffer) WriteString(s string) (int, error) {
+	m := b.grow(len(s))
+	b.buf = b.buf[:m+len(s)]
+	return copy(b.buf[m:], s), nil
+}
+
+// WriteByte implements io.ByteWriter, the error is always nil.
+func (b *Buffer) WriteByte(c byte) error {
+	m := b.grow(1)
+	b.buf = b.buf[:m+1]
+	b.buf[m] = c
+	return nil
+}
+
+// ReadFrom implements io.ReaderFrom, it reads from r until io.EOF.
+func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for {
+		m := b.grow(minRead)
+		n, err := r.Read(b.buf[m:cap(b.buf)])
+		if n < 0 {
+			panic("slab.Buffer: reader returned negative count from Read")
+		}
+		b.buf = b.buf[:m+n]
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Read implements io.Reader, the error is io.EOF when the buffer is empty and p is not.
+func (b *Buffer) Read(p []byte) (int, error) {
+	if b.Len() == 0 {
+		b.reuse()
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, b.buf[b.off:])
+	b.off += n
+	return n, nil
+}
+
+// ReadByte implements io.ByteReader.
+func (b *Buffer) ReadByte() (byte, error) {
+	if b.Len() == 0 {
+		b.reuse()
+		return 0, io.EOF
+	}
+	c := b.buf[b.off]
+	b.off++
+	return c, nil
+}
+
+// UnreadByte implements io.ByteScanner.
+func (b *Buffer) UnreadByte() error {
+	if b.off == 0 {
+		return errors.New("slab.Buffer: UnreadByte: previous operation was not a successful read")
+	}
+	b.off--
+	return nil
+}
+
+// WriteTo implements io.WriterTo, it writes the unread bytes to w until the buffer is empty or an error occurs.
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	if b.Len() == 0 {
+		b.reuse()
+		return 0, nil
+	}
+	n, err := w.Write(b.buf[b.off:])
+	if n > b.Len() {
+		panic("slab.Buffer: invalid Write count")
+	}
+	b.off += n
+	if err == nil && b.Len() != 0 {
+		err = io.ErrShortWrite
+	}
+	if b.Len() == 0 {
+		b.reuse()
+	}
+	return int64(n), err
+}
+
+// reuse drop the read bytes but keep the []byte.
+func (b *Buffer) reuse() {
+	b.buf = b.buf[:0]
+	b.off = 0
+}
+
+// Reset release the []byte into the pool and empty the buffer, the buffer can be written again.
+func (b *Buffer) Reset() {
+	if b.buf != nil {
+		b.pool.Free(b.buf)
+		b.buf = nil
+	}
+	b.off = 0
+}
+
+// Close release the []byte into the pool as Reset, the error is always nil.
+func (b *Buffer) Close() error {
+	b.Reset()
+	return nil
+}
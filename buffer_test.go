@@ -0,0 +1,103 @@
+package slab
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_Buffer(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	b := pool.Buffer(100)
+	utest.EqualNow(t, b.Cap(), 128)
+	utest.EqualNow(t, pool.Stats().InUse, 128)
+
+	b.WriteString("hello")
+	b.WriteByte(' ')
+	b.Write([]byte("world"))
+	utest.EqualNow(t, b.String(), "hello world")
+
+	c, err := b.ReadByte()
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, c, byte('h'))
+	utest.IsNilNow(t, b.UnreadByte())
+	p := make([]byte, 6)
+	n, err := b.Read(p)
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, string(p[:n]), "hello ")
+	utest.EqualNow(t, b.Len(), 5)
+
+	// grow into the next class, the old chunk is released.
+	b.Write(bytes.Repeat([]byte{'x'}, 200))
+	utest.EqualNow(t, b.Cap(), 256)
+	utest.EqualNow(t, pool.Stats().InUse, 256)
+	utest.EqualNow(t, b.Len(), 205)
+	utest.EqualNow(t, string(b.Bytes()[:5]), "world")
+
+	var w bytes.Buffer
+	m, err := b.WriteTo(&w)
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, m, int64(205))
+	utest.EqualNow(t, b.Len(), 0)
+	n, err = b.Read(p)
+	utest.EqualNow(t, n, 0)
+	utest.EqualNow(t, err, io.EOF)
+
+	b.Reset()
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	b.WriteString("again")
+	utest.EqualNow(t, b.String(), "again")
+	utest.IsNilNow(t, b.Close())
+	utest.IsNilNow(t, b.Close())
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_Buffer_ReadFrom(t *testing.T) {
+	pool := NewAtomPool(128, 4096, 2, 8192)
+	b := NewBuffer(pool, 0)
+	data := bytes.Repeat([]byte("0123456789"), 300)
+	n, err := b.ReadFrom(bytes.NewReader(data))
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, n, int64(len(data)))
+	utest.Assert(t, bytes.Equal(b.Bytes(), data))
+	utest.EqualNow(t, pool.Stats().InUse, 4096)
+
+	got, err := ioutil.ReadAll(b)
+	utest.IsNilNow(t, err)
+	utest.Assert(t, bytes.Equal(got, data))
+	utest.IsNilNow(t, b.Close())
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+
+	// the data beyond maxSize is in a []byte made by make(), Close is still safe.
+	b = NewBuffer(pool, 0)
+	b.Write(make([]byte, 5000))
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, b.Close())
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_Buffer_GrowLarge(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	b := NewBuffer(pool, 0)
+	for i := 0; i < 100000; i++ {
+		b.WriteByte(byte(i))
+	}
+	utest.EqualNow(t, b.Len(), 100000)
+	utest.EqualNow(t, b.Bytes()[99999], byte(99999%256))
+	// the []byte doubles beyond maxSize instead of growing by each write.
+	utest.Assert(t, pool.Stats().Allocs <= 20, "too many allocs", pool.Stats().Allocs)
+	utest.IsNilNow(t, b.Close())
+
+	// a strict pool still grows to the size can be pooled.
+	pool = NewAtomPool(128, 1000, 2, 1000, WithStrict())
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{128, 256, 512, 1000})
+	b = NewBuffer(pool, 500)
+	b.Write(make([]byte, 500))
+	b.Write(make([]byte, 100))
+	utest.EqualNow(t, b.Cap(), 1000)
+	utest.IsNilNow(t, b.Close())
+}
@@ -16,7 +16,7 @@ type ChanPool struct {
 // pageSize is the memory size of each slab class.
 func NewChanPool(minSize, maxSize, factor, pageSize int) *ChanPool {
 	pool := &ChanPool{make([]chanClass, 0, 10), minSize, maxSize}
-	for chunkSize := minSize; chunkSize <= maxSize && chunkSize <= pageSize; chunkSize *= factor {
+	for chunkSize := minSize; chunkSize > 0 && chunkSize <= maxSize && chunkSize <= pageSize; chunkSize = growSize(chunkSize, factor) {
 		c := chanClass{
 			size:   chunkSize,
 			page:   make([]byte, pageSize),
@@ -0,0 +1,90 @@
+package slab
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+)
+
+// CheckInvariants verify the structure of every slab class, it returns the first broken invariant found.
+// The pool must be quiescent, no Alloc or Free is running during the check.
+//
+// For each class it checks:
+// every chunk lies within its page and aligns to the chunk size;
+// no chunk appears twice in the free list;
+// only the chunks in the free list are marked as free;
+// the free chunks plus the chunks in use counted by Stats equal to all the chunks.
+func (pool *AtomPool) CheckInvariants() error {
+	for i := 0; i < len(pool.classes); i++ {
+		if err := pool.classes[i].checkInvariants(); err != nil {
+			return fmt.Errorf("slab: class %d: %s", pool.classes[i].size, err)
+		}
+	}
+	if grown := pool.grownPool(); grown != nil {
+		return grown.CheckInvariants()
+	}
+	return nil
+}
+
+func (c *class) checkInvariants() error {
+	chunks := c.chunkCount()
+	for i := 0; i < chunks; i++ {
+		chk := c.chunkAt(i)
+		begin, end := c.pageBegin, c.pageEnd
+		if i >= len(c.chunks) {
+			p := c.extraPages()[i/len(c.chunks)-1]
+			begin, end = p.begin, p.end
+		}
+		ptr := uintptr(unsafe.Pointer(&chk.mem[0]))
+		if ptr < begin || ptr > end || (ptr-begin)%uintptr(c.size) != 0 {
+			return fmt.Errorf("chunk %d is not aligned in the page", i)
+		}
+		if len(chk.mem) != c.size || cap(chk.mem) != c.size {
+			return fmt.Errorf("chunk %d has bad size", i)
+		}
+	}
+
+	free := make([]bool, chunks)
+	n := 0
+	visit := func(i uint64) error {
+		if i >= uint64(chunks) {
+			return fmt.Errorf("free list has bad index %d", i)
+		}
+		if free[i] {
+			return fmt.Errorf("chunk %d appears twice in the free list", i)
+		}
+		free[i] = true
+		n++
+		return nil
+	}
+	if c.fifo != nil {
+		q := c.fifo
+		for pos := q.dequeuePos; pos != q.enqueuePos; pos++ {
+			if err := visit(q.slots[pos&q.mask].index); err != nil {
+				return err
+			}
+		}
+	} else {
+		heads := []uint64{atomic.LoadUint64(&c.head)}
+		for i := range c.shards {
+			heads = append(heads, atomic.LoadUint64(&c.shards[i].head))
+		}
+		for _, head := range heads {
+			for ; head != 0; head = c.chunkAt(int(head>>32 - 1)).next {
+				if err := visit(head>>32 - 1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for i := 0; i < chunks; i++ {
+		if !free[i] && c.chunkAt(i).next != 0 {
+			return fmt.Errorf("chunk %d is in use but marked as free", i)
+		}
+	}
+	if inUse := c.loadPops() - c.loadPushes(); uint64(n)+inUse != uint64(chunks) {
+		return fmt.Errorf("%d free chunks and %d chunks in use, but the class has %d chunks", n, inUse, chunks)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AtomPool_CheckInvariants(t *testing.T) {
+	for _, pool := range []*AtomPool{
+		NewAtomPool(128, 1024, 2, 1024),
+		NewAtomPool(128, 1024, 2, 1024, WithFIFO()),
+	} {
+		utest.IsNilNow(t, pool.CheckInvariants())
+
+		temp := make([][]byte, 0, 16)
+		for i := 0; i < 16; i++ {
+			temp = append(temp, pool.Alloc(100*i))
+		}
+		utest.IsNilNow(t, pool.CheckInvariants())
+
+		for i := 0; i < len(temp); i += 2 {
+			pool.Free(temp[i])
+		}
+		utest.IsNilNow(t, pool.CheckInvariants())
+	}
+}
+
+func Test_AtomPool_CheckInvariants_Broken(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	pool.Alloc(128)
+	pool.classes[0].pops++
+	utest.NotNilNow(t, pool.CheckInvariants())
+	pool.classes[0].pops--
+
+	pool.classes[0].chunks[0].next = 2 << 32
+	utest.NotNilNow(t, pool.CheckInvariants())
+
+	// link the chunk in use to itself as the head of the free list, the list has a loop now.
+	pool.classes[0].chunks[0].next = 1 << 32
+	pool.classes[0].head = 1 << 32
+	utest.NotNilNow(t, pool.CheckInvariants())
+}
@@ -0,0 +1,7 @@
+//go:build !slab_unsafe
+// +build !slab_unsafe
+
+package slab
+
+// chunkChecks turns on the Bad Chunk and Double Free checks of Free, build with -tags slab_unsafe to strip them.
+const chunkChecks = true
@@ -0,0 +1,7 @@
+//go:build slab_unsafe
+// +build slab_unsafe
+
+package slab
+
+// chunkChecks is off with the slab_unsafe build tag, Free trusts the caller entirely.
+const chunkChecks = false
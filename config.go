@@ -0,0 +1,229 @@
+package slab
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PoolConfig is the whole configuration of an AtomPool as a plain value, it can be marshaled to JSON and back,
+// see NewAtomPoolFromConfig and AtomPool.Config.
+// The options of funcs, WithLogger, WithOversizeAllocator and WithAllocInterceptor, can't be kept in a value,
+// pass them to NewAtomPoolFromConfig again.
+type PoolConfig struct {
+	MinSize  int
+	MaxSize  int
+	Factor   int // the growth factor of NewAtomPool, ignored if ClassSizes is given
+	PageSize int
+	// ClassSizes are the chunk sizes from the smallest to the largest, they replace the growth by Factor,
+	// so the pools created by NewAtomPoolN or NewAtomPoolFunc, or grown by GrowMax, are kept exactly.
+	ClassSizes []int `json:",omitempty"`
+
+	LimitWaste       bool        // WithMaxWaste of MaxWaste
+	MaxWaste         float64     `json:",omitempty"`
+	LargerClasses    int         `json:",omitempty"` // WithLargerClasses
+	FIFO             bool        `json:",omitempty"` // WithFIFO
+	GuardPages       bool        `json:",omitempty"` // WithGuardPages
+	OSPages          bool        `json:",omitempty"` // WithOSPages
+	CASStats         bool        `json:",omitempty"` // WithCASStats
+	DepthCheck       bool        `json:",omitempty"` // WithDepthCheck
+	BindNUMA         bool        `json:",omitempty"` // WithNUMANode of NUMANode
+	NUMANode         int         `json:",omitempty"`
+	PoisonOnAlloc    bool        `json:",omitempty"` // WithPoisonOnAlloc of PoisonPattern
+	PoisonPattern    byte        `json:",omitempty"`
+	Spin             int         `json:",omitempty"` // WithSpin
+	ClassCapFallback bool        `json:",omitempty"` // WithClassCapFallback
+	Epochs           bool        `json:",omitempty"` // WithEpochs
+	LeakDetection    bool        `json:",omitempty"` // WithLeakDetection
+	MaxPages         int         `json:",omitempty"` // WithMaxPages
+	Mmap             bool        `json:",omitempty"` // WithMmap
+	Shards           int         `json:",omitempty"` // WithShards
+	Strict           bool        `json:",omitempty"` // WithStrict
+	StrictFree       bool        `json:",omitempty"` // WithStrictFree
+	Zeroing          ZeroPolicy  `json:",omitempty"` // WithZeroing
+	Alignment        int         `json:",omitempty"` // WithAlignment
+	RateLimits       []RateLimit `json:",omitempty"` // WithRateLimit of each
+}
+
+// NewAtomPoolFromConfig create a lock-free slab allocation memory pool of config,
+// options are applied after the options of config.
+// It returns an error if config is not valid, instead of panic like NewAtomPool.
+func NewAtomPoolFromConfig(config PoolConfig, options ...Option) (*AtomPool, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	options = append(config.options(), options...)
+	if len(config.ClassSizes) == 0 {
+		return NewAtomPool(config.MinSize, config.MaxSize, config.Factor, config.PageSize, options...), nil
+	}
+	sizes := append([]int(nil), config.ClassSizes...)
+	pool := newAtomPool(sizes, config.MinSize, config.MaxSize, config.PageSize, nil, options)
+	pool.factor = config.Factor
+	return pool, nil
+}
+
+// NewAtomPoolWithOptions create a lock-free slab allocation memory pool like NewAtomPool,
+// but it returns an error for the arguments or options that NewAtomPool accepts silently,
+// e.g. a factor less than 2 that never grows, or a maxSize larger than pageSize that leaves the large sizes unpooled.
+// Use WithMaxPages to bound the pages of each class, and WithStrict to never alloc from the Go heap.
+func NewAtomPoolWithOptions(minSize, maxSize, factor, pageSize int, options ...Option) (*AtomPool, error) {
+	if maxSize > pageSize {
+		return nil, errors.New("slab.NewAtomPoolWithOptions: maxSize is larger than pageSize")
+	}
+	// apply the options to a scratch pool to validate them before any page is alloc.
+	scratch := &AtomPool{minSize: minSize, maxSize: maxSize, pageSize: pageSize, factor: factor}
+	for _, option := range options {
+		option(scratch)
+	}
+	config := scratch.Config()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return NewAtomPool(minSize, maxSize, factor, pageSize, options...), nil
+}
+
+func (config *PoolConfig) validate() error {
+	if config.MinSize < 1 || config.MaxSize < config.MinSize || config.PageSize < 1 {
+		return errors.New("slab.PoolConfig: bad MinSize, MaxSize or PageSize")
+	}
+	if len(config.ClassSizes) == 0 && config.Factor < 2 {
+		return errors.New("slab.PoolConfig: Factor must be at least 2")
+	}
+	for i, size := range config.ClassSizes {
+		if size > config.MaxSize || size > config.PageSize || (i > 0 && size <= config.ClassSizes[i-1]) {
+			return fmt.Errorf("slab.PoolConfig: bad class size %d", size)
+		}
+	}
+	if config.LimitWaste && (config.MaxWaste < 0 || config.MaxWaste >= 1) {
+		return errors.New("slab.PoolConfig: MaxWaste must be in [0, 1)")
+	}
+	if config.LargerClasses < 0 || config.Spin < 0 || config.NUMANode < 0 || config.MaxPages < 0 || config.Shards < 0 {
+		return errors.New("slab.PoolConfig: negative LargerClasses, Spin, NUMANode, MaxPages or Shards")
+	}
+	if config.Alignment < 0 || config.Alignment&(config.Alignment-1) != 0 {
+		return errors.New("slab.PoolConfig: Alignment must be a power of 2")
+	}
+	if config.Zeroing < ZeroNone || config.Zeroing > ZeroOnFree {
+		return fmt.Errorf("slab.PoolConfig: bad Zeroing %d", config.Zeroing)
+	}
+	for _, r := range config.RateLimits {
+		if r.PerSecond <= 0 {
+			return fmt.Errorf("slab.PoolConfig: bad rate limit of class size %d", r.ClassSize)
+		}
+	}
+	return nil
+}
+
+func (config *PoolConfig) options() []Option {
+	var options []Option
+	if config.LimitWaste {
+		options = append(options, WithMaxWaste(config.MaxWaste))
+	}
+	if config.LargerClasses > 0 {
+		options = append(options, WithLargerClasses(config.LargerClasses))
+	}
+	if config.FIFO {
+		options = append(options, WithFIFO())
+	}
+	if config.GuardPages {
+		options = append(options, WithGuardPages())
+	}
+	if config.OSPages {
+		options = append(options, WithOSPages())
+	}
+	if config.CASStats {
+		options = append(options, WithCASStats())
+	}
+	if config.DepthCheck {
+		options = append(options, WithDepthCheck())
+	}
+	if config.BindNUMA {
+		options = append(options, WithNUMANode(config.NUMANode))
+	}
+	if config.PoisonOnAlloc {
+		options = append(options, WithPoisonOnAlloc(config.PoisonPattern))
+	}
+	if config.Spin > 0 {
+		options = append(options, WithSpin(config.Spin))
+	}
+	if config.ClassCapFallback {
+		options = append(options, WithClassCapFallback())
+	}
+	if config.MaxPages > 0 {
+		options = append(options, WithMaxPages(config.MaxPages))
+	}
+	if config.Mmap {
+		options = append(options, WithMmap())
+	}
+	if config.Shards > 0 {
+		options = append(options, WithShards(config.Shards))
+	}
+	if config.LeakDetection {
+		options = append(options, WithLeakDetection())
+	}
+	if config.Alignment > 0 {
+		options = append(options, WithAlignment(config.Alignment))
+	}
+	if config.Zeroing != ZeroNone {
+		options = append(options, WithZeroing(config.Zeroing))
+	}
+	if config.StrictFree {
+		options = append(options, WithStrictFree())
+	}
+	if config.Strict {
+		options = append(options, WithStrict())
+	}
+	if config.Epochs {
+		options = append(options, WithEpochs())
+	}
+	for _, r := range config.RateLimits {
+		options = append(options, WithRateLimit(r.ClassSize, r.PerSecond, r.Burst))
+	}
+	return options
+}
+
+// Config returns the configuration of the pool, NewAtomPoolFromConfig creates an identical pool from it.
+// ClassSizes is always filled, Factor is 0 if the pool is not created by NewAtomPool.
+// The classes added by GrowMax are included, the pages added by Expand are not.
+func (pool *AtomPool) Config() PoolConfig {
+	config := PoolConfig{
+		MinSize:          pool.minSize,
+		MaxSize:          pool.maxSize,
+		Factor:           pool.factor,
+		PageSize:         pool.pageSize,
+		ClassSizes:       pool.ClassSizes(),
+		LimitWaste:       pool.limitWaste,
+		LargerClasses:    pool.larger,
+		FIFO:             pool.fifo,
+		GuardPages:       pool.guardPages,
+		OSPages:          pool.osPages,
+		CASStats:         pool.casStats,
+		DepthCheck:       pool.depthCheck,
+		PoisonOnAlloc:    pool.poisonOnAlloc,
+		Spin:             pool.spins,
+		ClassCapFallback: pool.classCapFallback,
+		Epochs:           pool.epochs,
+		LeakDetection:    pool.leakDetection,
+		MaxPages:         pool.maxPages,
+		Mmap:             pool.mmap,
+		Shards:           pool.shards,
+		Strict:           pool.strict,
+		StrictFree:       pool.strictFree,
+		Zeroing:          pool.zeroing,
+		Alignment:        pool.align,
+		RateLimits:       append([]RateLimit(nil), pool.rateLimits...),
+	}
+	if pool.limitWaste {
+		config.MaxWaste = pool.maxWaste
+	}
+	if pool.numaNode > 0 {
+		config.BindNUMA = true
+		config.NUMANode = pool.numaNode - 1
+	}
+	if pool.poisonOnAlloc {
+		config.PoisonPattern = pool.poisonPattern
+	}
+	for grown := pool.grownPool(); grown != nil; grown = grown.grownPool() {
+		config.MaxSize = grown.maxSize
+	}
+	return config
+}
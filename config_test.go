@@ -0,0 +1,132 @@
+package slab
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_PoolConfig(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 4096, WithMaxWaste(0.5), WithLargerClasses(1),
+		WithSpin(8), WithRateLimit(256, 1000, 10), WithPoisonOnAlloc(0xAA))
+	config := pool.Config()
+	utest.EqualNow(t, config.Factor, 2)
+	utest.DeepEqualNow(t, config.ClassSizes, []int{128, 256, 512, 1024})
+
+	data, err := json.Marshal(config)
+	utest.IsNilNow(t, err)
+	var config2 PoolConfig
+	utest.IsNilNow(t, json.Unmarshal(data, &config2))
+	utest.DeepEqualNow(t, config2, config)
+
+	pool2, err := NewAtomPoolFromConfig(config2)
+	utest.IsNilNow(t, err)
+	utest.DeepEqualNow(t, pool2.Config(), config)
+	utest.DeepEqualNow(t, pool2.ChunkCounts(), pool.ChunkCounts())
+	utest.NotNilNow(t, pool2.classes[1].limit)
+	utest.EqualNow(t, pool2.Alloc(100)[0], byte(0xAA))
+}
+
+func Test_PoolConfig_ClassSizes(t *testing.T) {
+	pool := NewAtomPoolN(100, 1000, 5, 4096)
+	utest.IsNilNow(t, pool.GrowMax(2000))
+	config := pool.Config()
+	utest.EqualNow(t, config.Factor, 0)
+	utest.EqualNow(t, config.MaxSize, 2000)
+
+	pool2, err := NewAtomPoolFromConfig(config)
+	utest.IsNilNow(t, err)
+	utest.DeepEqualNow(t, pool2.ClassSizes(), pool.ClassSizes())
+	utest.EqualNow(t, cap(pool2.Alloc(1500)), cap(pool.Alloc(1500)))
+}
+
+func Test_PoolConfig_Validate(t *testing.T) {
+	bad := []PoolConfig{
+		{MinSize: 0, MaxSize: 1024, Factor: 2, PageSize: 4096},
+		{MinSize: 128, MaxSize: 64, Factor: 2, PageSize: 4096},
+		{MinSize: 128, MaxSize: 1024, Factor: 1, PageSize: 4096},
+		{MinSize: 128, MaxSize: 1024, PageSize: 4096, ClassSizes: []int{256, 128}},
+		{MinSize: 128, MaxSize: 1024, Factor: 2, PageSize: 4096, LimitWaste: true, MaxWaste: 1},
+		{MinSize: 128, MaxSize: 1024, Factor: 2, PageSize: 4096, Spin: -1},
+		{MinSize: 128, MaxSize: 1024, Factor: 2, PageSize: 4096, RateLimits: []RateLimit{{128, 0, 1}}},
+	}
+	for _, config := range bad {
+		_, err := NewAtomPoolFromConfig(config)
+		utest.NotNilNow(t, err)
+	}
+}
+
+func Test_NewAtomPoolWithOptions(t *testing.T) {
+	pool, err := NewAtomPoolWithOptions(128, 1024, 2, 1024, WithMaxPages(2), WithStrict())
+	utest.IsNilNow(t, err)
+	utest.Assert(t, pool.Config().Strict)
+
+	_, err = NewAtomPoolWithOptions(128, 1024, 1, 1024)
+	utest.NotNilNow(t, err)
+	_, err = NewAtomPoolWithOptions(128, 2048, 2, 1024)
+	utest.NotNilNow(t, err)
+	_, err = NewAtomPoolWithOptions(0, 1024, 2, 1024)
+	utest.NotNilNow(t, err)
+	_, err = NewAtomPoolWithOptions(128, 1024, 2, 1024, WithMaxPages(-1))
+	utest.NotNilNow(t, err)
+	_, err = NewAtomPoolWithOptions(128, 1024, 2, 1024, WithMaxWaste(2))
+	utest.NotNilNow(t, err)
+}
+
+func Test_WithStrict(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithStrict())
+	mems := make([][]byte, 0, 8)
+	for i := 0; i < 8; i++ {
+		mem, err := pool.TryAlloc(128)
+		utest.IsNilNow(t, err)
+		mems = append(mems, mem)
+	}
+	utest.IsNilNow(t, pool.Alloc(128))
+	_, err := pool.TryAlloc(128)
+	utest.EqualNow(t, err, ErrExhausted)
+	utest.IsNilNow(t, pool.Alloc(2000))
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(3))
+
+	// Realloc keeps mem when it can't grow.
+	mem := pool.Alloc(1000)
+	utest.IsNilNow(t, pool.Realloc(mems[0], 1000))
+	pool.Free(mem)
+	grown := pool.Realloc(mems[0], 1000)
+	utest.EqualNow(t, cap(grown), 1024)
+	mems[0] = pool.Alloc(128)
+	utest.Assert(t, mems[0] != nil)
+
+	func() {
+		defer func() {
+			utest.EqualNow(t, recover(), ErrExhausted)
+		}()
+		pool.Buffer(2000)
+	}()
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_WithStrict_Interceptor(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithStrict(), WithAllocInterceptor(func(size int) (int, bool) {
+		return 1024, size > 0
+	}))
+	mem := pool.Alloc(1000)
+	utest.EqualNow(t, cap(mem), 1024)
+	utest.IsNilNow(t, pool.Alloc(1000))
+	_, err := pool.TryAlloc(100)
+	utest.EqualNow(t, err, ErrExhausted)
+	utest.IsNilNow(t, pool.Alloc(0))
+	pool.Free(mem)
+	utest.EqualNow(t, len(pool.Alloc(1000)), 1000)
+}
+
+func Test_AllocPair_Interceptor(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithStrict(), WithAllocInterceptor(func(size int) (int, bool) {
+		return 1024, size > 0
+	}))
+	header, payload := pool.AllocPair(16, 100)
+	utest.IsNilNow(t, payload)
+	utest.IsNilNow(t, header)
+	header, _ = pool.AllocPair(16, 0)
+	utest.IsNilNow(t, header)
+}
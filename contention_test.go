@@ -0,0 +1,59 @@
+//go:build go1.13
+// +build go1.13
+
+package slab
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// benchmarkContention run Alloc and Free of a small class on many goroutines until b.N operations done.
+// It reports the fairness as the operations of the slowest goroutine divided by the fastest one's.
+func benchmarkContention(b *testing.B, spins int) {
+	pool := NewAtomPool(128, 128, 2, 128*64, WithSpin(spins))
+	goroutines := runtime.GOMAXPROCS(0) * 4
+	ops := make([]int64, goroutines)
+	var total int64
+	var wg sync.WaitGroup
+	b.ResetTimer()
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(n *int64) {
+			defer wg.Done()
+			for atomic.AddInt64(&total, 1) <= int64(b.N) {
+				pool.Free(pool.Alloc(128))
+				*n++
+			}
+		}(&ops[i])
+	}
+	wg.Wait()
+	b.StopTimer()
+
+	min, max := ops[0], ops[0]
+	for _, n := range ops {
+		if n < min {
+			min = n
+		}
+		if n > max {
+			max = n
+		}
+	}
+	if max > 0 {
+		b.ReportMetric(float64(min)/float64(max), "fairness")
+	}
+}
+
+func Benchmark_AtomPool_Contention_Yield(b *testing.B) {
+	benchmarkContention(b, 0)
+}
+
+func Benchmark_AtomPool_Contention_Spin16(b *testing.B) {
+	benchmarkContention(b, 16)
+}
+
+func Benchmark_AtomPool_Contention_Spin256(b *testing.B) {
+	benchmarkContention(b, 256)
+}
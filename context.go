@@ -0,0 +1,29 @@
+//go:build go1.7
+// +build go1.7
+
+package slab
+
+import "context"
+
+// AllocContext alloc a []byte like Alloc, the []byte is released into the pool when ctx is done.
+// The []byte can still be released by Free before ctx is done, it's not released twice,
+// but it must not be used or released after ctx is done.
+// Each pooled []byte costs a goroutine that waits for ctx until it's released.
+func (pool *AtomPool) AllocContext(ctx context.Context, size int) []byte {
+	mem := pool.Alloc(size)
+	if ctx.Done() == nil || !pool.WouldAccept(mem) {
+		return mem
+	}
+	stop := make(chan struct{})
+	id := pool.track(mem, stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			if pool.untrack(mem, id) {
+				pool.Free(mem)
+			}
+		case <-stop:
+		}
+	}()
+	return mem
+}
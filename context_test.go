@@ -0,0 +1,98 @@
+//go:build go1.7
+// +build go1.7
+
+package slab
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/funny/utest"
+)
+
+func waitFree(pool *AtomPool, n int) bool {
+	for i := 0; i < 1000; i++ {
+		if pool.Stats().InUse == n {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return false
+}
+
+func Test_AtomPool_AllocContext(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mem1 := pool.AllocContext(ctx, 128)
+	mem2 := pool.AllocContext(ctx, 256)
+	utest.EqualNow(t, len(mem1), 128)
+	utest.EqualNow(t, len(mem2), 256)
+	utest.EqualNow(t, pool.Stats().InUse, 384)
+
+	pool.Free(mem1)
+	utest.EqualNow(t, pool.Stats().InUse, 256)
+	utest.EqualNow(t, pool.tracked, int32(1))
+
+	cancel()
+	utest.Assert(t, waitFree(pool, 0))
+	utest.EqualNow(t, pool.tracked, int32(0))
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_AllocContext_Reuse(t *testing.T) {
+	pool := NewAtomPool(128, 128, 2, 128)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the chunk freed by hand and alloc again by Alloc must not be released when ctx is done.
+	mem := pool.AllocContext(ctx, 128)
+	pool.Free(mem)
+	mem = pool.Alloc(128)
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	utest.EqualNow(t, pool.Stats().InUse, 128)
+	pool.Free(mem)
+}
+
+func Test_AtomPool_AllocContext_Background(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.AllocContext(context.Background(), 128)
+	utest.EqualNow(t, pool.tracked, int32(0))
+	pool.Free(mem)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mem = pool.AllocContext(ctx, 2048)
+	utest.EqualNow(t, len(mem), 2048)
+	utest.EqualNow(t, pool.tracked, int32(0))
+}
+
+func Test_AtomPool_AllocContext_Shards(t *testing.T) {
+	pool := NewAtomPool(128, 128, 2, 128*64)
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 64; i++ {
+		pool.AllocContext(ctx, 128)
+	}
+	used := 0
+	for i := range pool.trackShards {
+		if len(pool.trackShards[i].tracked) > 0 {
+			used++
+		}
+	}
+	utest.Assert(t, used > trackShardCount/2, "tracked chunks in few shards", used)
+	cancel()
+	utest.Assert(t, waitFree(pool, 0))
+}
+
+func Benchmark_AtomPool_AllocContext(b *testing.B) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Free(pool.AllocContext(ctx, 128))
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package slab
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// maxContiguous limits the number of chunks AllocContiguous can join.
+const maxContiguous = 8
+
+// AllocContiguous alloc a []byte of size larger than maxSize from up to 8 adjacent free chunks of a slab class.
+// The chunks are in the same page, so the []byte is a single contiguous memory region.
+// The larger classes are tried first, the []byte is made by make() if no class has enough adjacent free chunks.
+// The size not larger than maxSize is alloc by Alloc. Release the []byte by FreeContiguous.
+//
+// The free list of a class is taken out while it's searched, the Allocs from the class at the same time fallback.
+// It's not for the hot path, and it can't be used with WithFIFO.
+func (pool *AtomPool) AllocContiguous(size int) []byte {
+	if size <= pool.maxSize {
+		return pool.Alloc(size)
+	}
+	if !pool.fifo {
+		for i := len(pool.classes) - 1; i >= 0; i-- {
+			c := &pool.classes[i]
+			n := (size + c.size - 1) / c.size
+			if n > maxContiguous {
+				break
+			}
+			if n > len(c.chunks) {
+				continue
+			}
+			if mem := c.popRun(n); mem != nil {
+				if pool.logger != nil {
+					pool.logAlloc(size, c, true)
+				}
+				if pool.leakDetection {
+					pool.recordAlloc(mem)
+				}
+				return pool.poison(mem)[:size]
+			}
+		}
+	}
+	atomic.AddUint64(&pool.fallbacks, 1)
+	if pool.logger != nil {
+		pool.logAlloc(size, nil, false)
+	}
+	return pool.fallback(size)
+}
+
+// FreeContiguous release a []byte that alloc from AllocContiguous, the chunks are found by the address of mem.
+// mem must have the capacity returned by AllocContiguous.
+func (pool *AtomPool) FreeContiguous(mem []byte) {
+	ptr := pointerOf(mem)
+	i := pool.classForPointer(ptr)
+	if i < 0 {
+		pool.free(mem, cap(mem))
+		return
+	}
+	c := &pool.classes[i]
+	first := c.chunkIndex(ptr)
+	n := cap(mem) / c.size
+	if uintptr(unsafe.Pointer(&c.chunkAt(first).mem[0])) != ptr || cap(mem)%c.size != 0 || n == 0 ||
+		first%len(c.chunks)+n > len(c.chunks) {
+		panic("slab.AtomPool: Bad Chunk")
+	}
+	if n == 1 {
+		pool.free(mem, cap(mem))
+		return
+	}
+	if pool.leakDetection {
+		pool.recordFree(mem, c)
+	}
+	for j := first; j < first+n; j++ {
+		c.Push(c.chunkAt(j).mem)
+	}
+	if pool.logger != nil {
+		pool.logFree(cap(mem), c, true)
+	}
+}
+
+// popRun take n adjacent free chunks in the same page out of the free list, it returns nil if there are not.
+func (c *class) popRun(n int) []byte {
+	old := c.detach()
+	if old == 0 {
+		return nil
+	}
+	perPage := len(c.chunks)
+	free := make([]bool, c.chunkCount())
+	for i := old >> 32; i != 0; i = atomic.LoadUint64(&c.chunkAt(int(i-1)).next) >> 32 {
+		free[i-1] = true
+	}
+	start := -1
+	for i, run := 0, 0; i < len(free); i++ {
+		if i%perPage == 0 {
+			run = 0
+		}
+		if free[i] {
+			run++
+		} else {
+			run = 0
+		}
+		if run == n {
+			start = i - n + 1
+			break
+		}
+	}
+
+	// link the other free chunks back in the same order.
+	first := -1
+	var tail *chunk
+	for i := old >> 32; i != 0; {
+		idx := int(i - 1)
+		chk := c.chunkAt(idx)
+		i = atomic.LoadUint64(&chk.next) >> 32
+		if start >= 0 && idx >= start && idx < start+n {
+			atomic.StoreUint64(&chk.next, 0)
+			continue
+		}
+		if tail == nil {
+			first = idx
+		} else {
+			atomic.StoreUint64(&tail.next, uint64(idx+1)<<32+uint64(chk.aba))
+		}
+		tail = chk
+	}
+	if tail != nil {
+		c.attach(first, tail)
+	}
+	if start < 0 {
+		return nil
+	}
+
+	atomic.AddUint64(&c.pops, uint64(n))
+	page := c.page
+	if start >= perPage {
+		page = c.extraPages()[start/perPage-1].mem
+	}
+	begin := start % perPage * c.size
+	mem := page[begin : begin+n*c.size : begin+n*c.size]
+	sanitizeAlloc(mem)
+	return mem
+}
@@ -0,0 +1,65 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AtomPool_AllocContiguous(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 4096)
+
+	mem := pool.AllocContiguous(3000)
+	utest.EqualNow(t, len(mem), 3000)
+	utest.EqualNow(t, cap(mem), 3*1024)
+	utest.EqualNow(t, pointerOf(mem)-pool.classes[3].pageBegin < 4096, true)
+	for i := range mem {
+		mem[i] = 1
+	}
+	utest.EqualNow(t, pool.Stats().InUse, 3*1024)
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(0))
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	// only one 1024 chunk left, 4 adjacent 512 chunks are used.
+	mem2 := pool.AllocContiguous(2000)
+	utest.EqualNow(t, cap(mem2), 4*512)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	pool.FreeContiguous(mem)
+	pool.FreeContiguous(mem2)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	mem = pool.AllocContiguous(100)
+	utest.EqualNow(t, cap(mem), 128)
+	pool.FreeContiguous(mem)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+}
+
+func Test_AtomPool_AllocContiguous_NoRun(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 4096)
+	// take every other 1024 chunk, the 2 free ones are not adjacent.
+	var mems [][]byte
+	for i := 0; i < 4; i++ {
+		mems = append(mems, pool.Alloc(1024))
+	}
+	pool.Free(mems[0])
+	pool.Free(mems[2])
+	// the other classes are too small for 32 * 128 bytes.
+	mem := pool.AllocContiguous(1024 * 2)
+	utest.EqualNow(t, cap(mem), 4*512)
+	mem = pool.AllocContiguous(4096)
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(1))
+	pool.FreeContiguous(mem)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_FreeContiguous_BadChunk(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 4096)
+	mem := pool.AllocContiguous(2048)
+
+	defer func() {
+		utest.EqualNow(t, recover(), "slab.AtomPool: Bad Chunk")
+	}()
+	pool.FreeContiguous(mem[1:])
+}
@@ -0,0 +1,48 @@
+package slab_test
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/lightpaw/slab"
+)
+
+// Many goroutines can share one AtomPool, each []byte is owned by the goroutine that alloc it until it's freed.
+// Each goroutine fills its []byte with its own sentinel and checks it before Free,
+// so a chunk handed to two goroutines at the same time is caught, run it with -race for the best result.
+func Example_concurrent() {
+	pool := slab.NewAtomPool(64, 1024, 2, 16*1024)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(sentinel byte) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				buf := pool.Alloc(64 + i%960)
+				for j := range buf {
+					buf[j] = sentinel
+				}
+				// let the other goroutines run, they would overwrite buf if they got the same chunk.
+				runtime.Gosched()
+				for j := range buf {
+					if buf[j] != sentinel {
+						errs <- fmt.Errorf("goroutine %d: byte %d is overwritten by %d", sentinel, j, buf[j])
+						return
+					}
+				}
+				pool.Free(buf)
+			}
+		}(byte(g))
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		fmt.Println(err)
+	}
+	fmt.Println("in use:", pool.Stats().InUse)
+	// Output: in use: 0
+}
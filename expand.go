@@ -0,0 +1,102 @@
+package slab
+
+import (
+	"errors"
+	"sync/atomic"
+	"unsafe"
+)
+
+// extraPage is a page added to a slab class by Expand, it has as many chunks as the class's first page.
+type extraPage struct {
+	mem    []byte
+	begin  uintptr
+	end    uintptr
+	chunks []chunk
+}
+
+// extraPages returns the pages added by Expand.
+func (c *class) extraPages() []*extraPage {
+	if p := atomic.LoadPointer(&c.extra); p != nil {
+		return *(*[]*extraPage)(p)
+	}
+	return nil
+}
+
+// Expand add at least additionalChunks chunks to the slab class that serve size, by adding pages of pageSize.
+// It's safe to call while the pool is in use, the new chunks are available to Alloc once it returns.
+// It returns an error if size is not served by a slab class, or the class is a FIFO queue of WithFIFO.
+func (pool *AtomPool) Expand(size, additionalChunks int) error {
+	i := pool.classForSize(size)
+	if i < 0 {
+		return errors.New("slab.AtomPool: no slab class for the size")
+	}
+	if pool.fifo {
+		return errors.New("slab.AtomPool: can't expand the FIFO classes")
+	}
+	if additionalChunks <= 0 {
+		return nil
+	}
+
+	c := &pool.classes[i]
+	pool.expandMu.Lock()
+	defer pool.expandMu.Unlock()
+	return pool.addPages(c, (additionalChunks+len(c.chunks)-1)/len(c.chunks))
+}
+
+// grow add a page to class c for the Alloc that found it empty, see WithMaxPages.
+// It returns false if c has maxPages pages already.
+func (pool *AtomPool) grow(c *class) bool {
+	pool.expandMu.Lock()
+	defer pool.expandMu.Unlock()
+	if !c.isEmpty() {
+		// grown by another Alloc, or some chunks freed in the meantime.
+		return true
+	}
+	if pool.closed || 1+len(c.extraPages()) >= pool.maxPages {
+		return false
+	}
+	return pool.addPages(c, 1) == nil
+}
+
+// addPages add n pages to class c and link their chunks into the free list, expandMu must be held.
+func (pool *AtomPool) addPages(c *class, n int) error {
+	perPage := len(c.chunks)
+	old := c.extraPages()
+	if uint64(len(old)+1+n)*uint64(perPage) >= 1<<32 {
+		return errors.New("slab.AtomPool: too many chunks in the slab class")
+	}
+	pages := make([]*extraPage, len(old), len(old)+n)
+	copy(pages, old)
+	first := (len(old) + 1) * perPage
+	gen := uint64(pool.pageGen)
+	for k := 0; k < n; k++ {
+		mem := pool.newPage()
+		p := &extraPage{mem: mem, chunks: make([]chunk, perPage)}
+		base := first + k*perPage
+		for j := 0; j < perPage; j++ {
+			chk := &p.chunks[j]
+			chk.mem = mem[j*c.size : (j+1)*c.size : (j+1)*c.size]
+			chk.aba = uint32(gen)
+			if base+j+1 < first+n*perPage {
+				chk.next = uint64(base+j+1+1)<<32 + gen
+			}
+			sanitizeFree(chk.mem)
+		}
+		p.begin = uintptr(unsafe.Pointer(&mem[0]))
+		p.end = uintptr(unsafe.Pointer(&p.chunks[perPage-1].mem[0]))
+		pages = append(pages, p)
+	}
+	// publish the pages before linking their chunks, so Pop can find every chunk on the free list.
+	atomic.StorePointer(&c.extra, unsafe.Pointer(&pages))
+
+	tail := &pages[len(pages)-1].chunks[perPage-1]
+	new := uint64(first+1)<<32 + gen
+	for {
+		head := atomic.LoadUint64(&c.head)
+		atomic.StoreUint64(&tail.next, head)
+		if atomic.CompareAndSwapUint64(&c.head, head, new) {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package slab
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AtomPool_Expand(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	utest.DeepEqualNow(t, pool.ChunkCounts(), []int{8, 4, 2, 1})
+
+	var mems [][]byte
+	for i := 0; i < 8; i++ {
+		mems = append(mems, pool.Alloc(100))
+	}
+	utest.IsNilNow(t, pool.Expand(100, 10))
+	utest.DeepEqualNow(t, pool.ChunkCounts(), []int{24, 4, 2, 1})
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	for i := 0; i < 16; i++ {
+		mems = append(mems, pool.Alloc(128))
+	}
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(0))
+	utest.EqualNow(t, pool.Stats().InUse, 24*128)
+	frag := pool.Fragmentation()
+	utest.EqualNow(t, frag[0], ClassFragmentation{Size: 128, Pages: 3, LivePages: 3})
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	for _, mem := range mems {
+		utest.EqualNow(t, pool.CapacityOf(mem), 128)
+		utest.Assert(t, pool.WouldAccept(mem))
+		pool.Free(mem)
+	}
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	pool.Clear()
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	utest.NotNilNow(t, pool.Expand(2048, 1))
+	utest.NotNilNow(t, NewAtomPool(128, 1024, 2, 1024, WithFIFO()).Expand(128, 1))
+}
+
+func Test_AtomPool_Expand_BadChunk(t *testing.T) {
+	if !chunkChecks {
+		t.Skip("the chunk checks are stripped by slab_unsafe")
+	}
+	pool := NewAtomPool(128, 128, 2, 128)
+	pool.Expand(128, 1)
+	mem1 := pool.Alloc(128)
+	mem2 := pool.Alloc(128)
+	pool.Free(mem1)
+	pool.Free(mem2)
+	mem := pool.Alloc(128)
+	pool.Alloc(128)
+	pool.Free(mem)
+
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	pool.FreeCap(mem[1:], 128)
+}
+
+func Test_AtomPool_Expand_Concurrent(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var mems [][]byte
+			for j := 0; j < 1000; j++ {
+				mems = append(mems, pool.Alloc(128))
+				if len(mems) == 16 {
+					for _, mem := range mems {
+						pool.Free(mem)
+					}
+					mems = mems[:0]
+				}
+			}
+			for _, mem := range mems {
+				pool.Free(mem)
+			}
+		}()
+	}
+	for i := 0; i < 16; i++ {
+		utest.IsNilNow(t, pool.Expand(128, 8))
+	}
+	wg.Wait()
+	utest.EqualNow(t, pool.ChunkCounts()[0], 17*8)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
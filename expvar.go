@@ -0,0 +1,16 @@
+package slab
+
+import "expvar"
+
+// Var returns an expvar.Var that shows the Stats of the pool as JSON, e.g. to publish it by expvar.Publish.
+func (pool *AtomPool) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		return pool.Stats()
+	})
+}
+
+// PublishExpvar publish the Stats of the pool by expvar under name, it's served at /debug/vars with the others.
+// Like expvar.Publish, it panics if name is used already.
+func (pool *AtomPool) PublishExpvar(name string) {
+	expvar.Publish(name, pool.Var())
+}
@@ -0,0 +1,92 @@
+package slab
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// fifoQueued marks chunk.next of the chunks in the fifo, so double free is still detected.
+const fifoQueued = 1
+
+// fifo is a bounded lock-free queue of chunk indexes, see WithFIFO.
+// Each slot has a sequence number that tells whether it is ready for enqueue or dequeue at a position.
+type fifo struct {
+	enqueuePos uint64
+	dequeuePos uint64
+	mask       uint64
+	slots      []fifoSlot
+}
+
+type fifoSlot struct {
+	seq   uint64
+	index uint64
+}
+
+// newFifo create a fifo that can hold all chunks of c, then move the chunks from the free list into it in order.
+func newFifo(c *class) *fifo {
+	c.head = 0
+	size := uint64(1)
+	for size < uint64(len(c.chunks)) {
+		size <<= 1
+	}
+	q := &fifo{
+		mask:  size - 1,
+		slots: make([]fifoSlot, size),
+	}
+	for i := uint64(0); i < size; i++ {
+		q.slots[i].seq = i
+	}
+	for i := 0; i < len(c.chunks); i++ {
+		c.chunks[i].next = fifoQueued
+		q.Enqueue(uint64(i))
+	}
+	return q
+}
+
+// Enqueue put a chunk index into the queue, it never fail because the queue can hold all chunks of the class.
+func (q *fifo) Enqueue(index uint64) {
+	for {
+		pos := atomic.LoadUint64(&q.enqueuePos)
+		slot := &q.slots[pos&q.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+		if seq == pos {
+			if atomic.CompareAndSwapUint64(&q.enqueuePos, pos, pos+1) {
+				slot.index = index
+				atomic.StoreUint64(&slot.seq, pos+1)
+				return
+			}
+		}
+		runtime.Gosched()
+	}
+}
+
+// Dequeue take the oldest chunk index from the queue, it returns false if the queue is empty.
+func (q *fifo) Dequeue() (uint64, bool) {
+	for {
+		pos := atomic.LoadUint64(&q.dequeuePos)
+		slot := &q.slots[pos&q.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+		switch {
+		case seq == pos+1:
+			if atomic.CompareAndSwapUint64(&q.dequeuePos, pos, pos+1) {
+				index := slot.index
+				atomic.StoreUint64(&slot.seq, pos+q.mask+1)
+				return index, true
+			}
+		case seq == pos:
+			// the slot is not filled yet, the queue is empty or an Enqueue is in progress.
+			return 0, false
+		}
+		runtime.Gosched()
+	}
+}
+
+// Len returns the number of chunk indexes in the queue, it's approximate while the queue is in use.
+func (q *fifo) Len() int {
+	dequeuePos := atomic.LoadUint64(&q.dequeuePos)
+	enqueuePos := atomic.LoadUint64(&q.enqueuePos)
+	if enqueuePos < dequeuePos {
+		return 0
+	}
+	return int(enqueuePos - dequeuePos)
+}
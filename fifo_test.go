@@ -0,0 +1,87 @@
+package slab
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_FIFO_Order(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithFIFO())
+	c := &pool.classes[0]
+	mem0 := pool.Alloc(128)
+	mem1 := pool.Alloc(128)
+	utest.EqualNow(t, c.chunkIndex(pointerOf(mem0)), 0)
+	utest.EqualNow(t, c.chunkIndex(pointerOf(mem1)), 1)
+
+	pool.Free(mem0)
+	for i := 2; i < len(c.chunks); i++ {
+		mem := pool.Alloc(128)
+		utest.EqualNow(t, c.chunkIndex(pointerOf(mem)), i)
+	}
+	mem := pool.Alloc(128)
+	utest.EqualNow(t, c.chunkIndex(pointerOf(mem)), 0)
+
+	mem = pool.Alloc(128)
+	utest.EqualNow(t, cap(mem), 128)
+	utest.EqualNow(t, c.chunkIndex(pointerOf(mem)), -1)
+}
+
+func Test_FIFO_DoubleFree(t *testing.T) {
+	if !chunkChecks {
+		t.Skip("the chunk checks are stripped by slab_unsafe")
+	}
+	pool := NewAtomPool(128, 1024, 2, 1024, WithFIFO())
+	mem := pool.Alloc(64)
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	pool.Free(mem)
+	pool.Free(mem)
+}
+
+func Test_FIFO_Clear(t *testing.T) {
+	if asanEnabled {
+		t.Skip("read the freed chunk")
+	}
+	pool := NewAtomPool(128, 1024, 2, 1024, WithFIFO())
+	mem := pool.Alloc(128)
+	for i := range mem {
+		mem[i] = 0xFF
+	}
+	pool.Free(mem)
+	pool.Clear()
+	for _, b := range mem {
+		utest.EqualNow(t, b, byte(0))
+	}
+	utest.EqualNow(t, pool.classes[0].fifo.Len(), len(pool.classes[0].chunks))
+}
+
+func Test_FIFO_Concurrent(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024, WithFIFO())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(b byte) {
+			defer wg.Done()
+			for j := 0; j < 10000; j++ {
+				mem := pool.Alloc(128)
+				mem[0] = b
+				pool.Free(mem)
+			}
+		}(byte(i))
+	}
+	wg.Wait()
+	utest.EqualNow(t, pool.classes[0].fifo.Len(), len(pool.classes[0].chunks))
+}
+
+func Benchmark_FIFO_AllocAndFree_128(b *testing.B) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024, WithFIFO())
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Free(pool.Alloc(128))
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package slab
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrFrameTooLarge is returned by FrameReader.ReadFrame when the length prefix is larger than the limit.
+var ErrFrameTooLarge = errors.New("slab.FrameReader: frame too large")
+
+// ReadFull alloc a []byte of n from the pool and fill it from r by io.ReadFull, r reads into the chunk directly.
+// The caller owns the []byte and must Free it once it succeeds. On error the []byte is released already and nil
+// is returned, io.ErrUnexpectedEOF if r ends in the middle, or io.EOF if r ends before any byte read.
+func (pool *AtomPool) ReadFull(r io.Reader, n int) ([]byte, error) {
+	return readFull(pool, r, n)
+}
+
+func readFull(pool Pool, r io.Reader, n int) ([]byte, error) {
+	mem := pool.Alloc(n)
+	if mem == nil {
+		return nil, ErrExhausted
+	}
+	if _, err := io.ReadFull(r, mem); err != nil {
+		pool.Free(mem)
+		return nil, err
+	}
+	return mem, nil
+}
+
+// FrameReader reads the frames of a 4 bytes big endian length prefix and the payload from r, e.g. a net.Conn.
+// Each payload is read straight into a []byte alloc from the pool, without a buffer in between.
+// It's not safe for concurrent use.
+type FrameReader struct {
+	pool      Pool
+	r         io.Reader
+	maxLength int
+	head      [4]byte
+}
+
+// NewFrameReader create a FrameReader reads r, the payloads are alloc from pool and can't be longer than maxLength.
+// Wrap r by bufio.Reader to save the syscalls of the small frames, the payload bytes are copied once more then.
+func NewFrameReader(pool Pool, r io.Reader, maxLength int) *FrameReader {
+	return &FrameReader{pool: pool, r: r, maxLength: maxLength}
+}
+
+// ReadFrame returns the payload of the next frame, the caller owns it and must release it by Free of the pool.
+// On error no []byte is returned and there is nothing to release:
+// io.EOF if r ends between frames, io.ErrUnexpectedEOF if r ends in a frame,
+// or ErrFrameTooLarge if the length prefix is larger than the limit, the reader can't go on after it.
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	if _, err := io.ReadFull(fr.r, fr.head[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(fr.head[:])
+	if uint64(n) > uint64(fr.maxLength) {
+		return nil, ErrFrameTooLarge
+	}
+	mem, err := readFull(fr.pool, fr.r, int(n))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return mem, err
+}
@@ -0,0 +1,53 @@
+package slab
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AtomPool_ReadFull(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem, err := pool.ReadFull(bytes.NewReader([]byte("hello world")), 5)
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, string(mem), "hello")
+	utest.EqualNow(t, pool.Stats().InUse, 128)
+	pool.Free(mem)
+
+	mem, err = pool.ReadFull(bytes.NewReader([]byte("hi")), 5)
+	utest.IsNilNow(t, mem)
+	utest.EqualNow(t, err, io.ErrUnexpectedEOF)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+}
+
+func Test_FrameReader(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	var stream bytes.Buffer
+	for _, payload := range []string{"first", "", "third"} {
+		binary.Write(&stream, binary.BigEndian, uint32(len(payload)))
+		stream.WriteString(payload)
+	}
+	binary.Write(&stream, binary.BigEndian, uint32(10))
+	stream.WriteString("short")
+
+	fr := NewFrameReader(pool, &stream, 1024)
+	for _, payload := range []string{"first", "", "third"} {
+		mem, err := fr.ReadFrame()
+		utest.IsNilNow(t, err)
+		utest.EqualNow(t, string(mem), payload)
+		pool.Free(mem)
+	}
+	_, err := fr.ReadFrame()
+	utest.EqualNow(t, err, io.ErrUnexpectedEOF)
+	_, err = fr.ReadFrame()
+	utest.EqualNow(t, err, io.EOF)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+
+	stream.Reset()
+	binary.Write(&stream, binary.BigEndian, uint32(2000))
+	_, err = NewFrameReader(pool, &stream, 1024).ReadFrame()
+	utest.EqualNow(t, err, ErrFrameTooLarge)
+}
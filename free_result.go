@@ -0,0 +1,56 @@
+package slab
+
+import "unsafe"
+
+// FreeResult tells what FreeStatus did with a []byte.
+type FreeResult int
+
+const (
+	// Reclaimed means the chunk is released into its slab class.
+	Reclaimed FreeResult = iota
+	// NotPooled means the []byte is not from the pool, such as a fallback or oversize []byte.
+	NotPooled
+	// CapMismatch means the []byte starts a chunk but its capacity is larger than the chunk size,
+	// such as a slice of the arena of NewAtomPoolOn, so it's not a chunk alloc from the pool.
+	CapMismatch
+	// BadPointer means the []byte points into a slab class but not to the beginning of a chunk.
+	BadPointer
+)
+
+func (r FreeResult) String() string {
+	switch r {
+	case Reclaimed:
+		return "Reclaimed"
+	case NotPooled:
+		return "NotPooled"
+	case CapMismatch:
+		return "CapMismatch"
+	case BadPointer:
+		return "BadPointer"
+	}
+	return "FreeResult(?)"
+}
+
+// FreeStatus release a []byte like Free, but returns why the []byte is not released instead of panic for a bad chunk.
+// Like Free, the chunk is found by the address, so the []byte resliced to a smaller capacity is released too.
+// The []byte not from the pool is still passed to the oversize allocator of WithOversizeAllocator.
+// A double free still panics.
+func (pool *AtomPool) FreeStatus(mem []byte) FreeResult {
+	ptr := pointerOf(mem)
+	p, i := pool.ownerOf(ptr)
+	if p == nil {
+		pool.free(mem, cap(mem))
+		return NotPooled
+	}
+	c := &p.classes[i]
+	if uintptr(unsafe.Pointer(&c.chunkAt(c.chunkIndex(ptr)).mem[0])) != ptr {
+		return BadPointer
+	}
+	if cap(mem) > c.size {
+		return CapMismatch
+	}
+	if !pool.free(mem, cap(mem)) {
+		return NotPooled
+	}
+	return Reclaimed
+}
@@ -0,0 +1,37 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AtomPool_FreeStatus(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+
+	mem := pool.Alloc(100)
+	utest.EqualNow(t, pool.FreeStatus(mem), Reclaimed)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+
+	utest.EqualNow(t, pool.FreeStatus(pool.Alloc(2048)), NotPooled)
+	utest.EqualNow(t, pool.FreeStatus(make([]byte, 128)), NotPooled)
+	utest.EqualNow(t, pool.FreeStatus(nil), NotPooled)
+
+	mem = pool.Alloc(256)
+	utest.EqualNow(t, pool.FreeStatus(mem[128:]), BadPointer)
+	utest.EqualNow(t, pool.Stats().InUse, 256)
+	utest.EqualNow(t, pool.FreeStatus(mem[:10:10]), Reclaimed)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	arena := make([]byte, 4096)
+	pool, err := NewAtomPoolOn(arena, 128, 1024, 2)
+	utest.IsNilNow(t, err)
+	mem = pool.Alloc(128)
+	utest.EqualNow(t, pointerOf(mem), pointerOf(arena))
+	utest.EqualNow(t, pool.FreeStatus(arena), CapMismatch)
+	utest.EqualNow(t, pool.Stats().InUse, 128)
+	utest.EqualNow(t, pool.FreeStatus(mem), Reclaimed)
+
+	utest.EqualNow(t, BadPointer.String(), "BadPointer")
+}
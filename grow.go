@@ -0,0 +1,68 @@
+package slab
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"unsafe"
+)
+
+// grownPool returns the pool of the classes added by GrowMax, or nil if there is none.
+func (pool *AtomPool) grownPool() *AtomPool {
+	return (*AtomPool)(atomic.LoadPointer(&pool.grown))
+}
+
+// GrowMax add slab classes for the sizes larger than maxSize up to newMaxSize, so the larger Allocs start to be pooled.
+// The new chunk sizes grow from the largest class by the factor of NewAtomPool, or by the ratio of the two largest classes
+// for the pools created without a factor, and a last class of exactly newMaxSize is added when the growth skips it. Like the other classes, the chunk sizes larger than pageSize are not pooled.
+//
+// The new classes are a pool of their own with the same options, chained after the pool.
+// Alloc and Free reach them only for the sizes larger than maxSize, the existing classes are untouched,
+// so it's safe to call while the pool is in use. Stats, ClassSizes, ChunkCounts and CheckInvariants include them.
+// It returns an error if newMaxSize is not larger than the largest size pooled, or no new class fits in a page.
+func (pool *AtomPool) GrowMax(newMaxSize int) error {
+	pool.expandMu.Lock()
+	defer pool.expandMu.Unlock()
+
+	last := pool
+	for g := last.grownPool(); g != nil; g = last.grownPool() {
+		last = g
+	}
+	if newMaxSize <= last.maxSize {
+		return errors.New("slab.AtomPool: newMaxSize must be larger than maxSize")
+	}
+	n := len(last.classes)
+	prev, ratio := last.maxSize, float64(pool.factor)
+	if n > 0 {
+		prev = last.classes[n-1].size
+	}
+	if ratio == 0 {
+		ratio = 2
+		if n > 1 {
+			ratio = float64(prev) / float64(last.classes[n-2].size)
+		}
+	}
+	var sizes []int
+	for size := prev; ; {
+		next := int(math.Floor(float64(size)*ratio + 0.5))
+		if next <= size {
+			next = size + 1
+		}
+		if next > newMaxSize || next > pool.pageSize {
+			break
+		}
+		if next > last.maxSize {
+			sizes = append(sizes, next)
+		}
+		size = next
+	}
+	if (len(sizes) == 0 || sizes[len(sizes)-1] < newMaxSize) && newMaxSize <= pool.pageSize {
+		sizes = append(sizes, newMaxSize)
+	}
+	if len(sizes) == 0 {
+		return errors.New("slab.AtomPool: newMaxSize is larger than pageSize")
+	}
+	grown := newAtomPool(sizes, last.maxSize+1, newMaxSize, pool.pageSize, nil, pool.options)
+	atomic.StorePointer(&last.grown, unsafe.Pointer(grown))
+	return nil
+}
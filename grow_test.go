@@ -0,0 +1,85 @@
+package slab
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AtomPool_GrowMax(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 8192)
+	mem := pool.Alloc(3000)
+	utest.EqualNow(t, pool.classForPointer(pointerOf(mem)), -1)
+	pool.Free(mem)
+
+	utest.IsNilNow(t, pool.GrowMax(3000))
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{128, 256, 512, 1024, 2048, 3000})
+	utest.DeepEqualNow(t, pool.ChunkCounts(), []int{64, 32, 16, 8, 4, 2})
+
+	mem = pool.Alloc(3000)
+	utest.EqualNow(t, cap(mem), 3000)
+	utest.EqualNow(t, pool.Stats().InUse, 3000)
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(1))
+	pool.Free(mem)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+
+	utest.IsNilNow(t, pool.GrowMax(8192))
+	utest.DeepEqualNow(t, pool.ClassSizes(), []int{128, 256, 512, 1024, 2048, 3000, 6000, 8192})
+	mem = pool.Alloc(5000)
+	utest.EqualNow(t, cap(mem), 6000)
+	pool.Free(mem)
+	utest.EqualNow(t, pool.Stats().Hits, uint64(2))
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	utest.NotNilNow(t, pool.GrowMax(8192))
+	utest.NotNilNow(t, pool.GrowMax(10000))
+}
+
+func Test_AtomPool_GrowMax_Lookup(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 8192)
+	utest.IsNilNow(t, pool.GrowMax(2048))
+	mem := pool.Alloc(2000)
+	utest.EqualNow(t, pool.CapacityOf(mem), 2048)
+	utest.Assert(t, pool.WouldAccept(mem))
+	utest.EqualNow(t, pool.FreeStatus(mem), Reclaimed)
+	utest.Assert(t, !pool.WouldAccept(mem))
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+
+	scope := pool.NewScope()
+	scope.Alloc(2000)
+	scope.Alloc(100)
+	utest.EqualNow(t, pool.Stats().InUse, 2048+128)
+	scope.Release()
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_GrowMax_Concurrent(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10000; j++ {
+				pool.Free(pool.Alloc(1000 + i*1000))
+			}
+		}(i)
+	}
+	utest.IsNilNow(t, pool.GrowMax(2048))
+	utest.IsNilNow(t, pool.GrowMax(4096))
+	wg.Wait()
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_GrowMax_AllocPair(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 8192)
+	utest.IsNilNow(t, pool.GrowMax(2048))
+	header, payload := pool.AllocPair(16, 2000)
+	utest.EqualNow(t, cap(header), 128)
+	utest.EqualNow(t, pool.CapacityOf(payload), 2048)
+	pool.FreePair(header, payload)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+}
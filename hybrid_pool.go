@@ -0,0 +1,66 @@
+package slab
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// HybridPool is an AtomPool with a sync.Pool based overflow.
+// The AtomPool is the bounded primary, when a slab class is exhausted the []byte comes from the overflow,
+// so bursts beyond the slab capacity are still pooled but can be collected by GC under pressure.
+// The overflow follows the WithZeroing and WithPoisonOnAlloc of the AtomPool like the chunks.
+type HybridPool struct {
+	slab     *AtomPool
+	overflow []sync.Pool
+}
+
+// NewHybridPool create a HybridPool on slab, the overflow has the same classes as slab.
+func NewHybridPool(slab *AtomPool) *HybridPool {
+	return &HybridPool{
+		slab:     slab,
+		overflow: make([]sync.Pool, len(slab.classes)),
+	}
+}
+
+// Alloc try alloc a []byte from slab class, if no free chunk in slab class Alloc will take one from the overflow.
+// The Allocs missed the slab classes are counted and logged like the fallbacks of slab, the sizes no class can serve
+// are alloc by slab's Alloc, and with WithStrict an empty overflow makes no new []byte.
+func (pool *HybridPool) Alloc(size int) []byte {
+	i := pool.slab.classForAlloc(size)
+	if i < 0 {
+		return pool.slab.Alloc(size)
+	}
+	mem, c := pool.slab.pop(i, size)
+	if mem != nil {
+		if pool.slab.logger != nil {
+			pool.slab.logAlloc(size, c, true)
+		}
+		return mem[:size]
+	}
+	atomic.AddUint64(&c.misses, 1)
+	atomic.AddUint64(&pool.slab.fallbacks, 1)
+	if pool.slab.logger != nil {
+		pool.slab.logAlloc(size, c, false)
+	}
+	if buf, ok := pool.overflow[i].Get().(*[]byte); ok {
+		mem = pool.slab.poison(*buf)
+	} else if pool.slab.strict {
+		return nil
+	} else {
+		mem = pool.slab.poisonNew(make([]byte, c.size))
+	}
+	return mem[:size]
+}
+
+// Free release a []byte that alloc from Pool.Alloc, the chunks go back to slab and the others go to the overflow.
+// The chunks are released by slab's Free, so the []byte tracked by a Scope or WithLeakDetection is released once.
+func (pool *HybridPool) Free(mem []byte) {
+	if i := pool.slab.classForFree(cap(mem)); i >= 0 && !pool.slab.Owns(mem) {
+		if pool.slab.zeroing == ZeroOnFree {
+			zero(mem[:cap(mem)])
+		}
+		pool.overflow[i].Put(&mem)
+		return
+	}
+	pool.slab.free(mem, cap(mem))
+}
@@ -0,0 +1,102 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_HybridPool_AllocAndFree(t *testing.T) {
+	pool := NewHybridPool(NewAtomPool(128, 1024, 2, 1024))
+	mem1 := pool.Alloc(1000)
+	utest.EqualNow(t, len(mem1), 1000)
+	utest.EqualNow(t, cap(mem1), 1024)
+	utest.Assert(t, pool.slab.classes[3].chunkIndex(pointerOf(mem1)) == 0)
+
+	mem2 := pool.Alloc(1000)
+	utest.EqualNow(t, len(mem2), 1000)
+	utest.EqualNow(t, cap(mem2), 1024)
+	utest.Assert(t, pool.slab.classes[3].chunkIndex(pointerOf(mem2)) == -1)
+
+	pool.Free(mem2)
+	utest.Assert(t, pool.slab.classes[3].head == 0)
+	pool.Free(mem1)
+	utest.Assert(t, pool.slab.classes[3].head != 0)
+}
+
+func Test_HybridPool_AllocLarge(t *testing.T) {
+	pool := NewHybridPool(NewAtomPool(128, 1024, 2, 1024))
+	mem := pool.Alloc(2048)
+	utest.EqualNow(t, len(mem), 2048)
+	utest.EqualNow(t, cap(mem), 2048)
+	pool.Free(mem)
+}
+
+func Test_HybridPool_FreeScoped(t *testing.T) {
+	pool := NewHybridPool(NewAtomPool(128, 1024, 2, 1024))
+	scope := pool.slab.NewScope()
+	mem := scope.Alloc(1000)
+	pool.Free(mem)
+	scope.Release()
+	utest.EqualNow(t, pool.slab.Stats().Classes[3].Free, 1)
+	utest.IsNilNow(t, pool.slab.CheckInvariants())
+
+	// a chunk resliced to a smaller capacity goes back to slab too.
+	mem = pool.Alloc(1000)
+	pool.Free(mem[:128:128])
+	utest.EqualNow(t, pool.slab.Stats().Classes[3].Free, 1)
+}
+
+func Test_HybridPool_Zeroing(t *testing.T) {
+	for _, policy := range []ZeroPolicy{ZeroOnAlloc, ZeroOnFree} {
+		pool := NewHybridPool(NewAtomPool(128, 1024, 2, 1024, WithZeroing(policy)))
+		chunk := pool.Alloc(1024)
+		mem := pool.Alloc(1024)
+		utest.Assert(t, !pool.slab.Owns(mem))
+		for i := range mem {
+			mem[i] = 0xAA
+		}
+		pool.Free(mem[:10])
+		if policy == ZeroOnFree {
+			utest.DeepEqualNow(t, mem, make([]byte, 1024))
+		}
+		reused := pool.Alloc(1024)
+		utest.DeepEqualNow(t, reused, make([]byte, 1024))
+		pool.Free(reused)
+		pool.Free(chunk)
+	}
+}
+
+func Test_HybridPool_Fallbacks(t *testing.T) {
+	pool := NewHybridPool(NewAtomPool(128, 1024, 2, 1024, WithStrict()))
+	chunk := pool.Alloc(1000)
+	utest.IsNilNow(t, pool.Alloc(1000))
+	utest.IsNilNow(t, pool.Alloc(2048))
+	stats := pool.slab.Stats()
+	utest.EqualNow(t, stats.Fallbacks, uint64(2))
+	utest.EqualNow(t, stats.Classes[3].Misses, uint64(1))
+	pool.Free(chunk)
+
+	var oversize int
+	pool = NewHybridPool(NewAtomPool(128, 1024, 2, 1024, WithOversizeAllocator(func(size int) []byte {
+		oversize++
+		return make([]byte, size)
+	}, func(mem []byte) {
+		oversize--
+	})))
+	mem := pool.Alloc(2048)
+	utest.EqualNow(t, oversize, 1)
+	utest.EqualNow(t, pool.slab.MaxOversize(), 2048)
+	pool.Free(mem)
+	utest.EqualNow(t, oversize, 0)
+}
+
+func Benchmark_HybridPool_AllocAndFree_128(b *testing.B) {
+	pool := NewHybridPool(NewAtomPool(128, 1024, 2, 64*1024))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Free(pool.Alloc(128))
+		}
+	})
+}
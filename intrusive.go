@@ -0,0 +1,67 @@
+package slab
+
+import (
+	"encoding/binary"
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// NodeHeaderSize is the size of the header that AllocNode reserve in front of the payload.
+const NodeHeaderSize = 8
+
+// AllocNode try alloc a []byte of size from the pool with a pointer-sized header in front of it.
+// The header is zeroed and can be used to link the nodes into intrusive lists by NodeHeader and SetNodeHeader.
+// The node must be released by FreeNode, not by Free. It returns nil when Alloc returns nil, see WithStrict.
+func (pool *AtomPool) AllocNode(size int) []byte {
+	mem := pool.Alloc(size + NodeHeaderSize)
+	if mem == nil {
+		return nil
+	}
+	for i := 0; i < NodeHeaderSize; i++ {
+		mem[i] = 0
+	}
+	return mem[NodeHeaderSize:]
+}
+
+// FreeNode release a node that alloc from AllocNode.
+// It panics like Free when the node is not alloc from AllocNode of this pool.
+func (pool *AtomPool) FreeNode(node []byte) {
+	if chunkChecks {
+		pool.checkNode(node)
+	}
+	pool.Free(nodeChunk(node))
+}
+
+// checkNode panics if node is in a chunk but doesn't follow the header at the chunk base,
+// e.g. a []byte alloc from Alloc is released by FreeNode.
+func (pool *AtomPool) checkNode(node []byte) {
+	ptr := pointerOf(node)
+	if p, i := pool.ownerOf(ptr); p != nil {
+		c := &p.classes[i]
+		if pointerOf(c.chunkAt(c.chunkIndex(ptr)).mem)+NodeHeaderSize != ptr {
+			atomic.AddUint64(&c.badFrees, 1)
+			panic("slab.AtomPool: Bad Node")
+		}
+	}
+}
+
+// NodeHeader returns the header value of a node that alloc from AllocNode.
+func NodeHeader(node []byte) uint64 {
+	return binary.LittleEndian.Uint64(nodeChunk(node))
+}
+
+// SetNodeHeader set the header value of a node that alloc from AllocNode.
+func SetNodeHeader(node []byte, v uint64) {
+	binary.LittleEndian.PutUint64(nodeChunk(node), v)
+}
+
+// nodeChunk returns the whole chunk, header included, of a node.
+func nodeChunk(node []byte) []byte {
+	var mem []byte
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&mem))
+	h.Data = pointerOf(node) - NodeHeaderSize
+	h.Len = len(node) + NodeHeaderSize
+	h.Cap = cap(node) + NodeHeaderSize
+	return mem
+}
@@ -0,0 +1,88 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AtomPool_AllocNode(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+
+	node := pool.AllocNode(100)
+	utest.EqualNow(t, len(node), 100)
+	utest.EqualNow(t, cap(node), 128-NodeHeaderSize)
+	utest.EqualNow(t, NodeHeader(node), uint64(0))
+
+	SetNodeHeader(node, 0x0102030405060708)
+	utest.EqualNow(t, NodeHeader(node), uint64(0x0102030405060708))
+	for i := range node {
+		node[i] = 0xFF
+	}
+	utest.EqualNow(t, NodeHeader(node), uint64(0x0102030405060708))
+
+	pool.FreeNode(node)
+	utest.EqualNow(t, pool.classes[0].free(pool.classes[0].pops, pool.classes[0].pushes), len(pool.classes[0].chunks))
+
+	node = pool.AllocNode(100)
+	utest.EqualNow(t, NodeHeader(node), uint64(0))
+	pool.FreeNode(node)
+}
+
+func Test_AtomPool_AllocNode_Fallback(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+
+	node := pool.AllocNode(1024)
+	utest.EqualNow(t, len(node), 1024)
+	SetNodeHeader(node, 42)
+	utest.EqualNow(t, NodeHeader(node), uint64(42))
+	pool.FreeNode(node)
+}
+
+func Test_AtomPool_FreeNode_DoubleFree(t *testing.T) {
+	if !chunkChecks {
+		t.Skip("the chunk checks are stripped by slab_unsafe")
+	}
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	node := pool.AllocNode(100)
+	pool.FreeNode(node)
+
+	defer func() {
+		utest.Assert(t, recover() != nil)
+	}()
+	pool.FreeNode(node)
+}
+
+func Test_AtomPool_FreeNode_BadChunk(t *testing.T) {
+	if !chunkChecks {
+		t.Skip("the chunk checks are stripped by slab_unsafe")
+	}
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	panics := func(f func()) (msg interface{}) {
+		defer func() {
+			msg = recover()
+		}()
+		f()
+		return
+	}
+
+	// the first chunk of a class, its header would be out of the page.
+	mem := pool.Alloc(100)
+	utest.EqualNow(t, panics(func() { pool.FreeNode(mem) }), "slab.AtomPool: Bad Node")
+	pool.Free(mem)
+
+	node := pool.AllocNode(100)
+	utest.EqualNow(t, panics(func() { pool.Free(node) }), "slab.AtomPool: Bad Chunk, release the node by FreeNode")
+	pool.FreeNode(node)
+	utest.EqualNow(t, panics(func() { pool.Free(node) }), "slab.AtomPool: Double Free")
+	utest.EqualNow(t, pool.Stats().Classes[0].Free, 8)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_AllocNode_Strict(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithStrict())
+	node := pool.AllocNode(1000)
+	utest.EqualNow(t, cap(node), 1024-NodeHeaderSize)
+	utest.IsNilNow(t, pool.AllocNode(1000))
+	pool.FreeNode(node)
+}
@@ -0,0 +1,151 @@
+package slab
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// maxLeakFrames limits the frames of a stack recorded by WithLeakDetection.
+const maxLeakFrames = 16
+
+// Leak is a chunk alloc from the pool and not freed yet, see CheckLeaks.
+type Leak struct {
+	Size  int    // chunk size
+	Stack string // the stack of the Alloc
+}
+
+// WithLeakDetection record the stack of every pooled Alloc and Free, it's a debugging tool for a missing Free.
+// CheckLeaks reports the chunks in use with their alloc stacks,
+// and a Double Free panic reports the alloc and the free stacks of the chunk.
+// It takes a lock and a runtime.Callers on every pooled Alloc and Free,
+// the chunks taken by AllocPair, AllocAny, AllocBatch and AllocContiguous are recorded too.
+func WithLeakDetection() Option {
+	return func(pool *AtomPool) {
+		pool.leakDetection = true
+	}
+}
+
+// recordAlloc record the stack of the caller of the Alloc that returns mem.
+func (pool *AtomPool) recordAlloc(mem []byte) {
+	stack := callers()
+	ptr := pointerOf(mem)
+	pool.leakMu.Lock()
+	if pool.allocStacks == nil {
+		pool.allocStacks = make(map[uintptr]allocRecord)
+		pool.freeStacks = make(map[uintptr]freeRecord)
+	}
+	pool.allocStacks[ptr] = allocRecord{cap(mem), stack}
+	delete(pool.freeStacks, ptr)
+	pool.leakMu.Unlock()
+}
+
+// recordFree record the stack of the Free of mem into class c, a chunk freed twice panics with its stacks.
+func (pool *AtomPool) recordFree(mem []byte, c *class) {
+	stack := callers()
+	ptr := pointerOf(mem)
+	pool.leakMu.Lock()
+	alloc, ok := pool.allocStacks[ptr]
+	if !ok {
+		free, freed := pool.freeStacks[ptr]
+		pool.leakMu.Unlock()
+		if freed {
+			atomic.AddUint64(&c.badFrees, 1)
+			panic(fmt.Sprintf("slab.AtomPool: Double Free\nalloc at:\n%sfirst freed at:\n%sfreed again at:\n%s",
+				formatStack(free.alloc), formatStack(free.free), formatStack(stack)))
+		}
+		// the chunk is not alloc by a recorded Alloc.
+		return
+	}
+	delete(pool.allocStacks, ptr)
+	pool.freeStacks[ptr] = freeRecord{alloc.stack, stack}
+	pool.leakMu.Unlock()
+}
+
+// Leaks returns the chunks alloc and not freed yet, in address order.
+// It's empty without WithLeakDetection.
+func (pool *AtomPool) Leaks() []Leak {
+	pool.leakMu.Lock()
+	ptrs := make([]uintptr, 0, len(pool.allocStacks))
+	for ptr := range pool.allocStacks {
+		ptrs = append(ptrs, ptr)
+	}
+	sort.Sort(uintptrs(ptrs))
+	leaks := make([]Leak, len(ptrs))
+	for i, ptr := range ptrs {
+		record := pool.allocStacks[ptr]
+		leaks[i] = Leak{record.size, formatStack(record.stack)}
+	}
+	pool.leakMu.Unlock()
+	return leaks
+}
+
+// CheckLeaks returns an error that lists the alloc stacks of the chunks not freed yet, or nil if there is none.
+// It's for the end of a test or a shutdown, when every chunk should be freed. See WithLeakDetection.
+func (pool *AtomPool) CheckLeaks() error {
+	leaks := pool.Leaks()
+	if len(leaks) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "slab: %d chunks not freed", len(leaks))
+	for _, leak := range leaks {
+		fmt.Fprintf(&buf, "\nchunk of %d alloc at:\n%s", leak.Size, leak.Stack)
+	}
+	return fmt.Errorf("%s", buf.String())
+}
+
+type allocRecord struct {
+	size  int
+	stack []uintptr
+}
+
+type freeRecord struct {
+	alloc []uintptr
+	free  []uintptr
+}
+
+type uintptrs []uintptr
+
+func (s uintptrs) Len() int           { return len(s) }
+func (s uintptrs) Less(i, j int) bool { return s[i] < s[j] }
+func (s uintptrs) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// methodPrefix is the prefix of the names of the pointer methods in the package, e.g. (*AtomPool).Alloc.
+var methodPrefix = strings.TrimSuffix(runtime.FuncForPC(reflect.ValueOf(formatStack).Pointer()).Name(), "formatStack") + "(*"
+
+// callers returns the stack above the Alloc or Free of the pool.
+// The frames of the pool methods are skipped, so the stack starts at the caller of Alloc, AllocPair, Buffer.Write
+// and so on, whichever the chunk is alloc or freed by.
+func callers() []uintptr {
+	pcs := make([]uintptr, 2*maxLeakFrames)
+	// skip runtime.Callers and callers.
+	pcs = pcs[:runtime.Callers(2, pcs)]
+	for len(pcs) > 1 {
+		if fn := runtime.FuncForPC(pcs[0] - 1); fn == nil || !strings.HasPrefix(fn.Name(), methodPrefix) {
+			break
+		}
+		pcs = pcs[1:]
+	}
+	if len(pcs) > maxLeakFrames {
+		pcs = pcs[:maxLeakFrames]
+	}
+	return pcs
+}
+
+func formatStack(stack []uintptr) string {
+	var buf bytes.Buffer
+	for _, pc := range stack {
+		fn := runtime.FuncForPC(pc - 1)
+		if fn == nil {
+			continue
+		}
+		file, line := fn.FileLine(pc - 1)
+		fmt.Fprintf(&buf, "\t%s\n\t\t%s:%d\n", fn.Name(), file, line)
+	}
+	return buf.String()
+}
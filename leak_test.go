@@ -0,0 +1,86 @@
+package slab
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_CheckLeaks(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithLeakDetection())
+	utest.IsNilNow(t, pool.CheckLeaks())
+
+	a := pool.Alloc(100)
+	b := pool.Alloc(500)
+	leaks := pool.Leaks()
+	utest.EqualNow(t, len(leaks), 2)
+	utest.Assert(t, strings.Contains(leaks[0].Stack, "Test_CheckLeaks"))
+	err := pool.CheckLeaks()
+	utest.NotNilNow(t, err)
+	utest.Assert(t, strings.Contains(err.Error(), "2 chunks not freed"))
+
+	pool.Free(a)
+	leaks = pool.Leaks()
+	utest.EqualNow(t, len(leaks), 1)
+	utest.EqualNow(t, leaks[0].Size, 512)
+	pool.Free(b)
+	utest.IsNilNow(t, pool.CheckLeaks())
+
+	// the []byte made by make() is not a leak.
+	pool.Alloc(2000)
+	utest.IsNilNow(t, pool.CheckLeaks())
+	utest.IsNilNow(t, NewAtomPool(128, 1024, 2, 1024).CheckLeaks())
+}
+
+func Test_LeakDetection_DoubleFree(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithLeakDetection())
+	mem := pool.Alloc(100)
+	pool.Free(mem)
+	defer func() {
+		err := recover()
+		utest.NotNilNow(t, err)
+		msg := err.(string)
+		utest.Assert(t, strings.HasPrefix(msg, "slab.AtomPool: Double Free"))
+		utest.Assert(t, strings.Contains(msg, "alloc at:"))
+		utest.Assert(t, strings.Contains(msg, "first freed at:"))
+		utest.Assert(t, strings.Contains(msg, "Test_LeakDetection_DoubleFree"))
+		utest.EqualNow(t, pool.Stats().BadFrees, uint64(1))
+	}()
+	pool.Free(mem)
+}
+
+func Test_LeakDetection_AllPops(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 4096, WithLeakDetection())
+	pool.Free(pool.Alloc(128))
+	header, payload := pool.AllocPair(100, 1000)
+	leaks := pool.Leaks()
+	utest.EqualNow(t, len(leaks), 2)
+	utest.Assert(t, strings.Contains(leaks[0].Stack, "Test_LeakDetection_AllPops"))
+	pool.FreePair(header, payload)
+	utest.IsNilNow(t, pool.CheckLeaks())
+
+	mem, _ := pool.AllocAny()
+	utest.EqualNow(t, len(pool.Leaks()), 1)
+	pool.Free(mem)
+	utest.IsNilNow(t, pool.CheckLeaks())
+
+	mem = pool.AllocContiguous(2048)
+	utest.Assert(t, pool.Owns(mem))
+	utest.EqualNow(t, len(pool.Leaks()), 1)
+	pool.FreeContiguous(mem)
+	utest.IsNilNow(t, pool.CheckLeaks())
+
+	// the header put back by a failed AllocPair is not a leak.
+	var mems [][]byte
+	for i := 0; i < 4; i++ {
+		mems = append(mems, pool.Alloc(1024))
+	}
+	header, payload = pool.AllocPair(100, 1000)
+	utest.Assert(t, !pool.Owns(header))
+	utest.EqualNow(t, len(pool.Leaks()), 4)
+	for _, mem := range mems {
+		pool.Free(mem)
+	}
+	utest.IsNilNow(t, pool.CheckLeaks())
+}
@@ -20,13 +20,13 @@ type LockPool struct {
 // pageSize is the memory size of each slab class.
 func NewLockPool(minSize, maxSize, factor, pageSize int) *LockPool {
 	n := 0
-	for chunkSize := minSize; chunkSize <= maxSize && chunkSize <= pageSize; chunkSize *= factor {
+	for chunkSize := minSize; chunkSize > 0 && chunkSize <= maxSize && chunkSize <= pageSize; chunkSize = growSize(chunkSize, factor) {
 		n++
 	}
 	pool := &LockPool{make([]lockClass, n), minSize, maxSize}
 
 	n = 0
-	for chunkSize := minSize; chunkSize <= maxSize && chunkSize <= pageSize; chunkSize *= factor {
+	for chunkSize := minSize; chunkSize > 0 && chunkSize <= maxSize && chunkSize <= pageSize; chunkSize = growSize(chunkSize, factor) {
 		c := &pool.classes[n]
 		c.size = chunkSize
 		c.page = make([]byte, pageSize)
@@ -0,0 +1,11 @@
+package slab
+
+import "syscall"
+
+// adviseSupported tells adviseFree gives the memory back to the OS.
+const adviseSupported = true
+
+// adviseFree tell the OS the OS pages of mem are not needed, they read zero when touched again.
+func adviseFree(mem []byte) {
+	syscall.Madvise(mem, syscall.MADV_DONTNEED)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package slab
+
+// adviseSupported tells adviseFree does nothing on this platform.
+const adviseSupported = false
+
+// adviseFree does nothing on the platforms other than Linux.
+func adviseFree(mem []byte) {}
@@ -0,0 +1,81 @@
+package slab
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// Collector is implemented by metrics systems (e.g. a Prometheus registry adapter) to receive the pool metrics.
+// The value functions are called by the metrics system when it reads the metrics, they are safe for concurrent use.
+type Collector interface {
+	// Counter register a metric that only increases.
+	Counter(name, help string, labels map[string]string, value func() float64)
+
+	// Gauge register a metric that can go up and down.
+	Gauge(name, help string, labels map[string]string, value func() float64)
+}
+
+// RegisterMetrics register the counters of pool into collector.
+// labels are added to every metric, e.g. to tell pools apart, the per class metrics get an extra "size" label.
+// The pool metrics include the classes added by GrowMax, the classes added after RegisterMetrics have no per class metrics.
+func RegisterMetrics(pool *AtomPool, collector Collector, labels map[string]string) {
+	collector.Counter("slab_allocs_total", "Number of []byte returned by Alloc.", labels, func() float64 {
+		return float64(pool.hits() + pool.allFallbacks())
+	})
+	collector.Counter("slab_hits_total", "Number of Allocs served by the slab classes.", labels, func() float64 {
+		return float64(pool.hits())
+	})
+	collector.Counter("slab_fallbacks_total", "Number of Allocs made by make().", labels, func() float64 {
+		return float64(pool.allFallbacks())
+	})
+	collector.Gauge("slab_in_use_bytes", "Memory size of the chunks in use.", labels, func() float64 {
+		inUse := 0
+		for p := pool; p != nil; p = p.grownPool() {
+			for i := 0; i < len(p.classes); i++ {
+				c := &p.classes[i]
+				free := c.free(c.loadPops(), c.loadPushes())
+				inUse += (c.chunkCount() - free) * c.size
+			}
+		}
+		return float64(inUse)
+	})
+
+	for p := pool; p != nil; p = p.grownPool() {
+		for i := 0; i < len(p.classes); i++ {
+			c := &p.classes[i]
+			classLabels := make(map[string]string, len(labels)+1)
+			for k, v := range labels {
+				classLabels[k] = v
+			}
+			classLabels["size"] = strconv.Itoa(c.size)
+			collector.Gauge("slab_class_free_chunks", "Number of free chunks in the slab class.", classLabels, func() float64 {
+				return float64(c.free(c.loadPops(), c.loadPushes()))
+			})
+			collector.Counter("slab_class_misses_total", "Number of Allocs of the slab class made by make().", classLabels, func() float64 {
+				return float64(atomic.LoadUint64(&c.misses))
+			})
+			collector.Counter("slab_class_bad_frees_total", "Number of Frees panicked for a bad chunk or a double free.", classLabels, func() float64 {
+				return float64(atomic.LoadUint64(&c.badFrees))
+			})
+		}
+	}
+}
+
+func (pool *AtomPool) hits() uint64 {
+	var hits uint64
+	for p := pool; p != nil; p = p.grownPool() {
+		for i := 0; i < len(p.classes); i++ {
+			c := &p.classes[i]
+			hits += since(c.loadPops(), atomic.LoadUint64(&c.resetPops))
+		}
+	}
+	return hits
+}
+
+func (pool *AtomPool) allFallbacks() uint64 {
+	var fallbacks uint64
+	for p := pool; p != nil; p = p.grownPool() {
+		fallbacks += atomic.LoadUint64(&p.fallbacks)
+	}
+	return fallbacks
+}
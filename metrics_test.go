@@ -0,0 +1,84 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+type testCollector struct {
+	counters map[string]func() float64
+	gauges   map[string]func() float64
+}
+
+func (c *testCollector) Counter(name, help string, labels map[string]string, value func() float64) {
+	c.counters[name+"{"+labels["size"]+"}"] = value
+}
+
+func (c *testCollector) Gauge(name, help string, labels map[string]string, value func() float64) {
+	c.gauges[name+"{"+labels["size"]+"}"] = value
+}
+
+func Test_RegisterMetrics(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	collector := &testCollector{
+		counters: make(map[string]func() float64),
+		gauges:   make(map[string]func() float64),
+	}
+	RegisterMetrics(pool, collector, map[string]string{"pool": "test"})
+	utest.EqualNow(t, len(collector.counters), 3+2*4)
+	utest.EqualNow(t, len(collector.gauges), 5)
+
+	mem := pool.Alloc(200)
+	pool.Alloc(2000)
+	utest.EqualNow(t, collector.counters["slab_allocs_total{}"](), float64(2))
+	utest.EqualNow(t, collector.counters["slab_hits_total{}"](), float64(1))
+	utest.EqualNow(t, collector.counters["slab_fallbacks_total{}"](), float64(1))
+	utest.EqualNow(t, collector.gauges["slab_in_use_bytes{}"](), float64(256))
+	utest.EqualNow(t, collector.gauges["slab_class_free_chunks{256}"](), float64(3))
+
+	pool.Free(mem)
+	utest.EqualNow(t, collector.gauges["slab_in_use_bytes{}"](), float64(0))
+	utest.EqualNow(t, collector.gauges["slab_class_free_chunks{256}"](), float64(4))
+
+	var mems [][]byte
+	for i := 0; i < 5; i++ {
+		mems = append(mems, pool.Alloc(1000))
+	}
+	utest.EqualNow(t, collector.counters["slab_class_misses_total{1024}"](), float64(4))
+	if !chunkChecks {
+		return
+	}
+	func() {
+		defer func() {
+			recover()
+		}()
+		mem := pool.Alloc(100)
+		pool.Free(mem)
+		pool.Free(mem)
+	}()
+	utest.EqualNow(t, collector.counters["slab_class_bad_frees_total{128}"](), float64(1))
+}
+
+func Test_RegisterMetrics_GrowMax(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 2048)
+	utest.IsNilNow(t, pool.GrowMax(2048))
+	collector := &testCollector{
+		counters: make(map[string]func() float64),
+		gauges:   make(map[string]func() float64),
+	}
+	RegisterMetrics(pool, collector, nil)
+	utest.EqualNow(t, len(collector.counters), 3+2*5)
+	utest.EqualNow(t, len(collector.gauges), 6)
+
+	mem := pool.Alloc(2000)
+	pool.Alloc(2000)
+	pool.Alloc(3000)
+	utest.EqualNow(t, collector.counters["slab_allocs_total{}"](), float64(3))
+	utest.EqualNow(t, collector.counters["slab_hits_total{}"](), float64(1))
+	utest.EqualNow(t, collector.counters["slab_fallbacks_total{}"](), float64(2))
+	utest.EqualNow(t, collector.counters["slab_class_misses_total{2048}"](), float64(1))
+	utest.EqualNow(t, collector.gauges["slab_in_use_bytes{}"](), float64(2048))
+	pool.Free(mem)
+	utest.EqualNow(t, collector.gauges["slab_class_free_chunks{2048}"](), float64(1))
+}
@@ -0,0 +1,22 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package slab
+
+// mmapSupported tells the pages of WithMmap are made by make().
+const mmapSupported = false
+
+// mmapGuarded fallback to make() on the platforms without mmap, see WithGuardPages.
+func mmapGuarded(size int) []byte {
+	return make([]byte, size)
+}
+
+// mmapPage fallback to make() on the platforms without mmap, see WithMmap.
+func mmapPage(size int) []byte {
+	return make([]byte, size)
+}
+
+// munmapPage does nothing on the platforms without mmap, the memory is collected by GC.
+func munmapPage(mem []byte) error {
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package slab
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapSupported tells the pages of WithMmap are mapped by mmap.
+const mmapSupported = true
+
+// mmapGuarded map a page of size bytes that is surrounded by PROT_NONE guard pages, see WithGuardPages.
+func mmapGuarded(size int) []byte {
+	pageSize := os.Getpagesize()
+	n := (size + pageSize - 1) / pageSize * pageSize
+	mem, err := syscall.Mmap(-1, 0, pageSize+n+pageSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		panic("slab: mmap failed: " + err.Error())
+	}
+	if err := syscall.Mprotect(mem[:pageSize], syscall.PROT_NONE); err != nil {
+		panic("slab: mprotect failed: " + err.Error())
+	}
+	if err := syscall.Mprotect(mem[pageSize+n:], syscall.PROT_NONE); err != nil {
+		panic("slab: mprotect failed: " + err.Error())
+	}
+	return mem[pageSize+n-size : pageSize+n : pageSize+n]
+}
+
+// mmapPage map size bytes of anonymous memory out of the Go heap, see WithMmap.
+func mmapPage(size int) []byte {
+	mem, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		panic("slab: mmap failed: " + err.Error())
+	}
+	return mem
+}
+
+// munmapPage unmap the memory mapped by mmapPage.
+func munmapPage(mem []byte) error {
+	return syscall.Munmap(mem)
+}
@@ -0,0 +1,78 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package slab
+
+import (
+	"os"
+	"runtime/debug"
+	"testing"
+	"unsafe"
+
+	"github.com/funny/utest"
+)
+
+func Test_GuardPages(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1000, WithGuardPages())
+	utest.EqualNow(t, len(pool.classes), 3)
+	for i := 0; i < len(pool.classes); i++ {
+		c := &pool.classes[i]
+		utest.EqualNow(t, len(c.page), 1000)
+		mem := pool.Alloc(c.size)
+		mem[0] = 1
+		pool.Free(mem)
+	}
+
+	c := &pool.classes[0]
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	*(*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(&c.page[0])) + uintptr(len(c.page)))) = 1
+	t.Fatal("write past the page not fault")
+}
+
+func Test_Mmap(t *testing.T) {
+	if !adviseSupported {
+		t.Skip("madvise is only used on Linux")
+	}
+	osPageSize := os.Getpagesize()
+	pool := NewAtomPool(1024, 4096, 2, 16*osPageSize, WithMmap(), WithMaxPages(2))
+	utest.EqualNow(t, len(pool.mapped), 3)
+	var mems [][]byte
+	for i := 0; i < 16*osPageSize/4096+1; i++ {
+		mem := pool.Alloc(4096)
+		mem[0] = 1
+		mems = append(mems, mem)
+	}
+	utest.EqualNow(t, len(pool.mapped), 4)
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(0))
+	for _, mem := range mems {
+		pool.Free(mem)
+	}
+
+	c := &pool.classes[0]
+	mem := pool.Alloc(1024)
+	c.page[len(c.page)-1] = 1
+	// the first OS page of the 1024 class holds the chunk in use, the 4096 class has 2 pages.
+	utest.EqualNow(t, pool.Reclaim(), (15+16+2*16)*osPageSize)
+	utest.EqualNow(t, c.page[len(c.page)-1], byte(0))
+	pool.Free(mem)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	utest.IsNilNow(t, pool.Close())
+	utest.EqualNow(t, len(pool.mapped), 0)
+	mem = pool.Alloc(1024)
+	utest.EqualNow(t, pool.classForPointer(pointerOf(mem)), -1)
+}
+
+func Test_Mmap_Shrink(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 4096, WithMmap())
+	mapped := len(pool.mapped)
+	utest.IsNilNow(t, pool.Expand(128, 64))
+	utest.EqualNow(t, len(pool.mapped), mapped+2)
+	utest.EqualNow(t, pool.Shrink(), 8192)
+	utest.EqualNow(t, len(pool.mapped), mapped)
+	utest.IsNilNow(t, pool.CheckInvariants())
+	utest.IsNilNow(t, pool.Close())
+}
@@ -0,0 +1,43 @@
+package slab
+
+// NUMAPool is a set of AtomPools, one for each NUMA node.
+// Alloc take the []byte from the pool of the node that the caller running on,
+// and Free release the []byte into the pool that owns it.
+type NUMAPool struct {
+	pools []*AtomPool
+}
+
+// NewNUMAPool create a NUMAPool of nodes AtomPools, the pages of each pool are placed on its node by WithNUMANode.
+// The other arguments are passed to NewAtomPool.
+func NewNUMAPool(nodes, minSize, maxSize, factor, pageSize int, options ...Option) *NUMAPool {
+	if nodes < 1 {
+		panic("slab.NewNUMAPool: nodes must be at least 1")
+	}
+	pool := &NUMAPool{make([]*AtomPool, nodes)}
+	for i := 0; i < nodes; i++ {
+		pool.pools[i] = NewAtomPool(minSize, maxSize, factor, pageSize, append(options[:len(options):len(options)], WithNUMANode(i))...)
+	}
+	return pool
+}
+
+// Node returns the pool of the NUMA node.
+func (pool *NUMAPool) Node(node int) *AtomPool {
+	return pool.pools[node]
+}
+
+// Alloc try alloc a []byte from the pool of the caller's NUMA node.
+func (pool *NUMAPool) Alloc(size int) []byte {
+	return pool.pools[currentNode()%len(pool.pools)].Alloc(size)
+}
+
+// Free release a []byte that alloc from NUMAPool.Alloc into the pool that owns it.
+func (pool *NUMAPool) Free(mem []byte) {
+	ptr := pointerOf(mem)
+	for _, p := range pool.pools {
+		if p.classForPointer(ptr) >= 0 {
+			p.Free(mem)
+			return
+		}
+	}
+	pool.pools[0].Free(mem)
+}
@@ -0,0 +1,47 @@
+package slab
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const mpolPreferred = 1
+
+// mmapNode map a page of size bytes that is placed on the NUMA node, see WithNUMANode.
+func mmapNode(size, node int) []byte {
+	mem, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		panic("slab: mmap failed: " + err.Error())
+	}
+	bindNode(mem, node)
+	return mem
+}
+
+// bindNode set the memory policy of the OS pages cover mem to prefer the NUMA node.
+// The pages are placed when they are touched first, so mem must not be touched before.
+// It's only a preference, the pages are placed on the other nodes if the node is out of memory or not exists.
+func bindNode(mem []byte, node int) error {
+	pageSize := uintptr(os.Getpagesize())
+	begin := pointerOf(mem) &^ (pageSize - 1)
+	end := (pointerOf(mem) + uintptr(len(mem)) + pageSize - 1) &^ (pageSize - 1)
+	mask := make([]uint64, node/64+1)
+	mask[node/64] |= 1 << uint(node%64)
+	_, _, errno := syscall.Syscall6(syscall.SYS_MBIND, begin, end-begin, mpolPreferred,
+		uintptr(unsafe.Pointer(&mask[0])), uintptr(len(mask)*64+1), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// currentNode returns the NUMA node of the CPU that the caller running on.
+// The goroutine may be moved to another CPU at any time, so it's only a hint.
+func currentNode() int {
+	var cpu, node uint32
+	_, _, errno := syscall.RawSyscall(sysGetcpu, uintptr(unsafe.Pointer(&cpu)), uintptr(unsafe.Pointer(&node)), 0)
+	if errno != 0 {
+		return 0
+	}
+	return int(node)
+}
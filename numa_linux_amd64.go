@@ -0,0 +1,4 @@
+package slab
+
+// sysGetcpu is missing in package syscall of linux/amd64.
+const sysGetcpu = 309
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package slab
+
+// mmapNode fallback to make() on the platforms without NUMA support, see WithNUMANode.
+func mmapNode(size, node int) []byte {
+	return make([]byte, size)
+}
+
+// bindNode does nothing on the platforms without NUMA support.
+func bindNode(mem []byte, node int) error {
+	return nil
+}
+
+// currentNode always returns 0 on the platforms without NUMA support.
+func currentNode() int {
+	return 0
+}
@@ -0,0 +1,42 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_NUMAPool(t *testing.T) {
+	pool := NewNUMAPool(2, 128, 1024, 2, 64*1024)
+	utest.EqualNow(t, pool.Node(0).numaNode, 1)
+	utest.EqualNow(t, pool.Node(1).numaNode, 2)
+
+	var mems [][]byte
+	for i := 0; i < 100; i++ {
+		mem := pool.Alloc(128)
+		utest.EqualNow(t, len(mem), 128)
+		for j := range mem {
+			mem[j] = byte(i)
+		}
+		mems = append(mems, mem)
+	}
+	for _, mem := range mems {
+		pool.Free(mem)
+	}
+	utest.EqualNow(t, pool.Node(0).Stats().InUse+pool.Node(1).Stats().InUse, 0)
+
+	// a []byte of another node's pool is released into that pool.
+	mem := pool.Node(1).Alloc(256)
+	pool.Free(mem)
+	utest.EqualNow(t, pool.Node(1).Stats().InUse, 0)
+	pool.Free(pool.Alloc(2048))
+}
+
+func Test_Option_WithNUMANode(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024, WithNUMANode(0), WithGuardPages())
+	mem := pool.Alloc(1024)
+	mem[0] = 1
+	pool.Free(mem)
+	utest.IsNilNow(t, pool.CheckInvariants())
+	utest.Assert(t, currentNode() >= 0)
+}
@@ -0,0 +1,118 @@
+package slab
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// mapPage map a page for WithMmap, it's recorded to be unmapped by Close.
+func (pool *AtomPool) mapPage(size int) []byte {
+	osPageSize := os.Getpagesize()
+	page := mmapPage((size + osPageSize - 1) / osPageSize * osPageSize)
+	pool.mapMu.Lock()
+	pool.mapped = append(pool.mapped, page)
+	pool.mapMu.Unlock()
+	return page[:size:size]
+}
+
+// Close unmap the pages of WithMmap, it does nothing for the pool without the option.
+// The pool must not be used after Close: every chunk must be freed before, and no Alloc or Free can run at the same time.
+// The free lists are emptied, so an Alloc after Close by mistake makes a new []byte instead of touching unmapped memory.
+func (pool *AtomPool) Close() error {
+	pool.expandMu.Lock()
+	defer pool.expandMu.Unlock()
+	if grown := pool.grownPool(); grown != nil {
+		if err := grown.Close(); err != nil {
+			return err
+		}
+	}
+	pool.mapMu.Lock()
+	defer pool.mapMu.Unlock()
+	if len(pool.mapped) == 0 {
+		return nil
+	}
+	pool.closed = true
+	for i := 0; i < len(pool.classes); i++ {
+		c := &pool.classes[i]
+		if c.fifo != nil {
+			for {
+				if _, ok := c.fifo.Dequeue(); !ok {
+					break
+				}
+			}
+		} else {
+			c.detach()
+		}
+	}
+	for _, page := range pool.mapped {
+		if err := munmapPage(page); err != nil {
+			return err
+		}
+	}
+	pool.mapped = nil
+	return nil
+}
+
+// Reclaim give the OS pages covered entirely by free chunks back to the OS by madvise, and returns their size.
+// The chunks stay on the free lists, the OS maps zero pages for them again when they are touched.
+// It only works with WithMmap on Linux, it returns 0 otherwise or for the FIFO classes.
+// Like Clear, the class being reclaimed makes new []byte for Alloc in the meantime.
+func (pool *AtomPool) Reclaim() int {
+	if !pool.mmap || !adviseSupported {
+		return 0
+	}
+	total := 0
+	for i := 0; i < len(pool.classes); i++ {
+		total += pool.classes[i].reclaim()
+	}
+	if grown := pool.grownPool(); grown != nil {
+		total += grown.Reclaim()
+	}
+	return total
+}
+
+func (c *class) reclaim() int {
+	if c.fifo != nil {
+		return 0
+	}
+	old := c.detach()
+	if old == 0 {
+		return 0
+	}
+	free := make([]bool, c.chunkCount())
+	var tail *chunk
+	for i := old >> 32; i != 0; i = atomic.LoadUint64(&tail.next) >> 32 {
+		free[i-1] = true
+		tail = c.chunkAt(int(i - 1))
+	}
+
+	osPageSize := uintptr(os.Getpagesize())
+	perPage := len(c.chunks)
+	total := 0
+	for start := 0; start < len(free); {
+		if !free[start] {
+			start++
+			continue
+		}
+		// a run of free chunks in the same page.
+		end := start + 1
+		for end < len(free) && free[end] && end%perPage != 0 {
+			end++
+		}
+		page := c.page
+		if start >= perPage {
+			page = c.extraPages()[start/perPage-1].mem
+		}
+		run := page[start%perPage*c.size : (end-1)%perPage*c.size+c.size]
+		begin := (pointerOf(run) + osPageSize - 1) &^ (osPageSize - 1)
+		stop := (pointerOf(run) + uintptr(len(run))) &^ (osPageSize - 1)
+		if stop > begin {
+			off := int(begin - pointerOf(run))
+			adviseFree(run[off : off+int(stop-begin)])
+			total += int(stop - begin)
+		}
+		start = end
+	}
+	c.attach(int(old>>32-1), tail)
+	return total
+}
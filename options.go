@@ -0,0 +1,271 @@
+package slab
+
+// Option configures an AtomPool, see NewAtomPool.
+type Option func(*AtomPool)
+
+// Logger receives the trace of AtomPool.Alloc and AtomPool.Free, see WithLogger.
+// classSize is the chunk size of the slab class that matched, or 0 if no class matched.
+// pooled reports whether the []byte came from or went back to the slab class.
+type Logger interface {
+	LogAlloc(size, classSize int, pooled bool)
+	LogFree(size, classSize int, pooled bool)
+}
+
+// WithLogger trace every Alloc and Free to logger, it's for debugging.
+// A nil logger disable the trace.
+func WithLogger(logger Logger) Option {
+	return func(pool *AtomPool) {
+		pool.logger = logger
+	}
+}
+
+// WithMaxWaste limit the internal waste of a chunk, ratio is the unused part of the chunk size.
+// When the best fit class wastes more than ratio, Alloc will make a []byte of the exact size instead.
+// e.g. with ratio 0.25 Alloc(1100) make a new []byte rather than waste 948 bytes of a 2048 bytes chunk.
+func WithMaxWaste(ratio float64) Option {
+	return func(pool *AtomPool) {
+		pool.maxWaste = ratio
+		pool.limitWaste = true
+	}
+}
+
+// WithLargerClasses let Alloc try at most n larger classes when the best fit class is empty,
+// rather than make a new []byte. It trades internal waste for less heap allocation under partial exhaustion.
+func WithLargerClasses(n int) Option {
+	return func(pool *AtomPool) {
+		pool.larger = n
+	}
+}
+
+// WithFIFO replace the LIFO free list of each class with a lock-free FIFO queue,
+// so a freed chunk is handed out again only after all the other free chunks.
+// It makes use-after-free bugs visible sooner in tests, since the freed memory is not reused at once.
+// The cost is cache locality: LIFO hands out the most recently used, likely cache hot, chunk.
+func WithFIFO() Option {
+	return func(pool *AtomPool) {
+		pool.fifo = true
+	}
+}
+
+// WithOversizeAllocator delegate the []byte larger than maxSize to a custom allocator, e.g. a mmap arena.
+// Alloc calls alloc for the size larger than maxSize, and Free calls free for the []byte has capacity larger than maxSize.
+// free can be nil if the allocator need no release. By default the oversize []byte is made by make() and collected by GC.
+func WithOversizeAllocator(alloc func(size int) []byte, free func(mem []byte)) Option {
+	return func(pool *AtomPool) {
+		pool.oversizeAlloc = alloc
+		pool.oversizeFree = free
+	}
+}
+
+// WithGuardPages back each class's page with mmap and put PROT_NONE guard pages around it,
+// so a write past the page faults at once instead of silently corrupt the memory next to it.
+// The page is placed right before the trailing guard page, so overruns are caught exactly.
+// It's an expensive debugging tool, and it has no effect on the platforms without mmap.
+func WithGuardPages() Option {
+	return func(pool *AtomPool) {
+		pool.guardPages = true
+	}
+}
+
+// WithOSPages round the backing memory of each class's page up to a multiple of os.Getpagesize(),
+// and start the page at an OS page, so the pages mapped by WithNUMANode are placed clearly.
+// The chunks are still carved from pageSize bytes, the rest of the backing memory is not used.
+// With WithGuardPages, the page still ends on the trailing guard page, and it starts at an OS page
+// only when pageSize is a multiple of the OS page size.
+func WithOSPages() Option {
+	return func(pool *AtomPool) {
+		pool.osPages = true
+	}
+}
+
+// WithCASStats count the CAS attempts and successes of the free lists in Push and Pop, see Stats.
+// A high attempts to successes ratio is a sign of contention.
+// Counting adds atomic operations to every Alloc and Free, so it's disabled by default.
+// The FIFO queue of WithFIFO is not counted.
+func WithCASStats() Option {
+	return func(pool *AtomPool) {
+		pool.casStats = true
+	}
+}
+
+// WithDepthCheck compare the free chunk count of a class against its chunk count on every Free,
+// a Free that would make the free list longer than the class is a bad chunk.
+// It catches double frees that race past the Double Free check before Pop hands out a chunk twice.
+func WithDepthCheck() Option {
+	return func(pool *AtomPool) {
+		pool.depthCheck = true
+	}
+}
+
+// WithNUMANode place the pages on the NUMA node on Linux, it does nothing on the other platforms.
+// The pages are mapped by mmap and never unmapped, and they are only prefer the node,
+// the OS place them on the other nodes if the node is out of memory. See NUMAPool.
+func WithNUMANode(node int) Option {
+	return func(pool *AtomPool) {
+		pool.numaNode = node + 1
+	}
+}
+
+// WithPoisonOnAlloc fill the whole chunk with pattern before Alloc returns it, the fallback []byte too.
+// It's a debug aid that makes the read of the bytes not written yet easy to notice, unlike zeroing.
+func WithPoisonOnAlloc(pattern byte) Option {
+	return func(pool *AtomPool) {
+		pool.poisonOnAlloc = true
+		pool.poisonPattern = pattern
+	}
+}
+
+// ZeroPolicy decides when the chunks are zeroed, see WithZeroing.
+type ZeroPolicy int
+
+const (
+	ZeroNone    ZeroPolicy = iota // the chunks keep the data of the previous user, as without WithZeroing
+	ZeroOnAlloc                   // the whole chunk is zeroed before Alloc returns it
+	ZeroOnFree                    // the whole chunk is zeroed when it's released by Free
+)
+
+// WithZeroing zero the chunks by policy, so a reused chunk never exposes the data of its previous user.
+// ZeroOnAlloc pays on every Alloc and covers the chunks written after they're freed by mistake,
+// ZeroOnFree pays on every Free and keeps the free chunks from holding the data, e.g. in a core dump.
+// The zeroing loop is compiled to memclr. The []byte made by make() is zero already,
+// and WithPoisonOnAlloc takes priority over ZeroOnAlloc.
+func WithZeroing(policy ZeroPolicy) Option {
+	return func(pool *AtomPool) {
+		pool.zeroing = policy
+	}
+}
+
+// WithAlignment lay out the classes so every chunk starts at a multiple of align, align must be a power of 2.
+// The chunk sizes, maxSize included, are rounded up to multiples of align and the pages start at align,
+// so AllocAligned of align or less costs nothing more than Alloc.
+// The overhead is the rounding, up to align-1 bytes of each chunk, and up to align bytes of each page.
+// The pages of WithGuardPages and the arena of NewAtomPoolOn are not aligned by it,
+// and the pages mapped by mmap are only aligned to the OS page.
+func WithAlignment(align int) Option {
+	if align < 1 || align&(align-1) != 0 {
+		panic("slab.WithAlignment: align must be a power of 2")
+	}
+	return func(pool *AtomPool) {
+		pool.align = align
+	}
+}
+
+// WithSpin let Alloc and Free retry a failed CAS of the free list at once for up to spins times,
+// then they yield the processor by runtime.Gosched after each failed CAS as without the option.
+// Spinning first saves the scheduler round trips when the contention is short,
+// yielding after lets the goroutine that keeps losing the race step aside instead of burning CPU.
+// The FIFO queue of WithFIFO is not affected.
+func WithSpin(spins int) Option {
+	return func(pool *AtomPool) {
+		pool.spins = spins
+	}
+}
+
+// WithRateLimit limit the Allocs served by the class that holds classSize to perSecond chunks per second,
+// with bursts of up to burst chunks. The Alloc over the limit is made by make() as if the class is empty,
+// so one tenant of a shared pool can't drain the class. WithLargerClasses still follow their own limits.
+// A token is taken before Pop, an Alloc that finds the class empty uses it up too.
+// The option can be given once for each class.
+func WithRateLimit(classSize int, perSecond float64, burst int) Option {
+	return func(pool *AtomPool) {
+		pool.rateLimits = append(pool.rateLimits, RateLimit{classSize, perSecond, burst})
+	}
+}
+
+// WithClassCapFallback let Alloc make the fallback []byte with the capacity of the class it would come from,
+// so the fallback []byte looks like a chunk to Free: its capacity matches the class, then Push finds it's not
+// in the class's pages and drops it. Recycle returns false for it, and true for the chunk from the class.
+// The fallback []byte can be appended up to the chunk size just like a pooled one.
+// The oversize []byte and the size rejected by WithMaxWaste are still made of the exact size.
+func WithClassCapFallback() Option {
+	return func(pool *AtomPool) {
+		pool.classCapFallback = true
+	}
+}
+
+// WithAllocInterceptor let interceptor decide how each Alloc is served, e.g. for A/B testing the class layouts.
+// interceptor is called at the top of Alloc with the requested size, it returns usePool false to make the []byte
+// by make(), or a different newSize to take the chunk of another class. The []byte still has the requested length,
+// a newSize smaller than the requested size is taken as the requested size, and Free just works as usual.
+// Without the option Alloc only checks a nil func.
+func WithAllocInterceptor(interceptor func(size int) (newSize int, usePool bool)) Option {
+	return func(pool *AtomPool) {
+		pool.interceptor = interceptor
+	}
+}
+
+// WithEpochs bump an epoch of the chunk on every Free, so a WeakRef can tell the chunk is freed or reused.
+// It adds an atomic operation to every Free.
+func WithEpochs() Option {
+	return func(pool *AtomPool) {
+		pool.epochs = true
+	}
+}
+
+// WithMaxPages let a class grow to at most maxPages pages, Alloc adds a page to the class it found empty
+// rather than make a new []byte, so bursts are still pooled while the memory is bounded.
+// The pages are added like Expand and never released. It has no effect on the FIFO classes of WithFIFO.
+func WithMaxPages(maxPages int) Option {
+	return func(pool *AtomPool) {
+		pool.maxPages = maxPages
+	}
+}
+
+// WithMmap back each class's page with anonymous mmap, so the pages are out of the Go heap,
+// the garbage collector neither counts them nor scans them. Close unmaps the pages, and Reclaim gives
+// the OS pages of the free chunks back by madvise. On the platforms without mmap the pages are made by make().
+// Unlike WithGuardPages, a write past the page is not caught.
+func WithMmap() Option {
+	return func(pool *AtomPool) {
+		pool.mmap = true
+	}
+}
+
+// WithStrict never alloc from the Go heap, Alloc returns nil instead of a []byte made by make()
+// when the class is exhausted, the size is not pooled or the interceptor of WithAllocInterceptor doesn't use the pool,
+// TryAlloc returns ErrExhausted then.
+// It's for the deployments that the memory must be predictable, use it with WithMaxPages to let the classes grow.
+// The sizes larger than maxSize still use the allocator of WithOversizeAllocator, if any.
+// The users of Alloc must check for nil, such as Realloc and Buffer; AllocNode, TinyPool and the others
+// that assume Alloc always succeeds are not for a strict pool.
+func WithStrict() Option {
+	return func(pool *AtomPool) {
+		pool.strict = true
+	}
+}
+
+// WithStrictFree let Free panic for a []byte the pool doesn't own, instead of leaving it to the garbage collector.
+// It catches a heap []byte passed to the wrong Free, but the fallback []byte made by make() panics too,
+// so use it with WithStrict, or check Owns before Free. The oversize []byte of WithOversizeAllocator is not foreign.
+func WithStrictFree() Option {
+	return func(pool *AtomPool) {
+		pool.strictFree = true
+	}
+}
+
+// WithShards split the free list of each class into n free lists, each on a cache line of its own,
+// so the goroutines hammering the same class don't all CAS the same head. A goroutine frees into and allocs from
+// the shard picked by its stack address, and takes the chunks of the other shards when its shard runs dry.
+// The pages added by Expand or WithMaxPages go to a shared list that is tried last.
+// It costs a few more loads per Alloc, so it only pays under heavy contention. It has no effect with WithFIFO.
+func WithShards(n int) Option {
+	return func(pool *AtomPool) {
+		pool.shards = n
+	}
+}
+
+func (pool *AtomPool) logAlloc(size int, c *class, pooled bool) {
+	classSize := 0
+	if c != nil {
+		classSize = c.size
+	}
+	pool.logger.LogAlloc(size, classSize, pooled)
+}
+
+func (pool *AtomPool) logFree(size int, c *class, pooled bool) {
+	classSize := 0
+	if c != nil {
+		classSize = c.size
+	}
+	pool.logger.LogFree(size, classSize, pooled)
+}
@@ -0,0 +1,348 @@
+package slab
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/funny/utest"
+)
+
+type testLogger struct {
+	logs []string
+}
+
+func (l *testLogger) LogAlloc(size, classSize int, pooled bool) {
+	l.logs = append(l.logs, fmt.Sprintf("alloc %d %d %v", size, classSize, pooled))
+}
+
+func (l *testLogger) LogFree(size, classSize int, pooled bool) {
+	l.logs = append(l.logs, fmt.Sprintf("free %d %d %v", size, classSize, pooled))
+}
+
+func Test_Option_WithLogger(t *testing.T) {
+	logger := &testLogger{}
+	pool := NewAtomPool(128, 1024, 2, 1024, WithLogger(logger))
+
+	mem1 := pool.Alloc(1000)
+	mem2 := pool.Alloc(1000)
+	mem3 := pool.Alloc(2048)
+	pool.Free(mem1)
+	pool.Free(mem2)
+	pool.Free(mem3)
+	pool.Free(make([]byte, 128))
+
+	utest.DeepEqualNow(t, logger.logs, []string{
+		"alloc 1000 1024 true",
+		"alloc 1000 1024 false",
+		"alloc 2048 0 false",
+		"free 1024 1024 true",
+		"free 1000 0 false",
+		"free 2048 0 false",
+		"free 128 128 false",
+	})
+}
+
+func Test_Option_WithNilLogger(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithLogger(nil))
+	pool.Free(pool.Alloc(128))
+}
+
+func Test_Option_WithMaxWaste(t *testing.T) {
+	pool := NewAtomPool(128, 4096, 2, 4096, WithMaxWaste(0.25))
+
+	mem := pool.Alloc(1100)
+	utest.EqualNow(t, cap(mem), 1100)
+	pool.Free(mem)
+
+	mem = pool.Alloc(1536)
+	utest.EqualNow(t, cap(mem), 2048)
+	pool.Free(mem)
+
+	mem = pool.Alloc(64)
+	utest.EqualNow(t, cap(mem), 64)
+
+	mem = pool.Alloc(96)
+	utest.EqualNow(t, cap(mem), 128)
+}
+
+func Test_Option_WithLargerClasses(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithLargerClasses(2))
+	for i := 0; i < 8; i++ {
+		pool.Alloc(128)
+	}
+	mem := pool.Alloc(128)
+	utest.EqualNow(t, len(mem), 128)
+	utest.EqualNow(t, cap(mem), 256)
+	pool.Free(mem)
+
+	for i := 0; i < 4; i++ {
+		pool.Alloc(256)
+	}
+	mem = pool.Alloc(128)
+	utest.EqualNow(t, cap(mem), 512)
+
+	mem = pool.Alloc(1000)
+	utest.EqualNow(t, cap(mem), 1024)
+	mem = pool.Alloc(1000)
+	utest.EqualNow(t, cap(mem), 1000)
+}
+
+func Test_Option_WithOversizeAllocator(t *testing.T) {
+	var allocs, frees []int
+	pool := NewAtomPool(128, 1024, 2, 1024, WithOversizeAllocator(
+		func(size int) []byte {
+			allocs = append(allocs, size)
+			return make([]byte, size, size*2)
+		},
+		func(mem []byte) {
+			frees = append(frees, cap(mem))
+		},
+	))
+
+	mem := pool.Alloc(2048)
+	utest.EqualNow(t, len(mem), 2048)
+	utest.EqualNow(t, cap(mem), 4096)
+	pool.Free(mem)
+
+	pool.Free(pool.Alloc(1024))
+	pool.Free(make([]byte, 100))
+	utest.DeepEqualNow(t, allocs, []int{2048})
+	utest.DeepEqualNow(t, frees, []int{4096})
+}
+
+func Test_Option_WithDepthCheck(t *testing.T) {
+	// the only chunk of the class is the tail of the free list, its next is 0 like a chunk in use.
+	pool := NewAtomPool(128, 128, 2, 128)
+	mem := pool.Alloc(128)
+	pool.Free(mem)
+	pool.Free(mem)
+	utest.Assert(t, pool.CheckInvariants() != nil)
+
+	pool = NewAtomPool(128, 128, 2, 128, WithDepthCheck())
+	mem = pool.Alloc(128)
+	pool.Free(mem)
+	defer func() {
+		utest.EqualNow(t, recover(), "slab.AtomPool: Bad Chunk")
+		utest.IsNilNow(t, pool.CheckInvariants())
+	}()
+	pool.Free(mem)
+}
+
+func Test_Option_WithPoisonOnAlloc(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithPoisonOnAlloc(0xDE))
+	mem := pool.Alloc(100)
+	for i := range mem {
+		mem[i] = 1
+	}
+	pool.Free(mem)
+
+	mem = pool.Alloc(100)
+	utest.EqualNow(t, cap(mem), 128)
+	for _, b := range mem[:cap(mem)] {
+		utest.EqualNow(t, b, byte(0xDE))
+	}
+	pool.Free(mem)
+
+	for _, b := range pool.Alloc(2048) {
+		utest.EqualNow(t, b, byte(0xDE))
+	}
+}
+
+func Test_Option_WithSpin(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024, WithSpin(16))
+	utest.EqualNow(t, pool.classes[0].spins, 16)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10000; j++ {
+				pool.Free(pool.Alloc(128))
+			}
+		}()
+	}
+	wg.Wait()
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_Option_WithOSPages(t *testing.T) {
+	osPageSize := uintptr(os.Getpagesize())
+	pool := NewAtomPool(128, 1024, 2, 1000, WithOSPages())
+	utest.DeepEqualNow(t, pool.ChunkCounts(), []int{7, 3, 1})
+	for i := 0; i < len(pool.classes); i++ {
+		c := &pool.classes[i]
+		utest.EqualNow(t, len(c.page), 1000)
+		utest.EqualNow(t, c.pageBegin%osPageSize, uintptr(0))
+	}
+	utest.IsNilNow(t, pool.Expand(128, 1))
+	utest.EqualNow(t, pool.classes[0].extraPages()[0].begin%osPageSize, uintptr(0))
+	pool.Free(pool.Alloc(512))
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_Option_WithRateLimit(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024, WithRateLimit(200, 0.001, 3))
+	utest.IsNilNow(t, pool.classes[0].limit)
+	utest.NotNilNow(t, pool.classes[1].limit)
+	for i := 0; i < 3; i++ {
+		mem := pool.Alloc(256)
+		utest.EqualNow(t, pool.classForPointer(pointerOf(mem)), 1)
+	}
+	mem := pool.Alloc(256)
+	utest.EqualNow(t, pool.classForPointer(pointerOf(mem)), -1)
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(1))
+	mem = pool.Alloc(128)
+	utest.EqualNow(t, pool.classForPointer(pointerOf(mem)), 0)
+}
+
+func Test_Limiter(t *testing.T) {
+	l := newLimiter(10, 2)
+	now := int64(time.Hour)
+	utest.Assert(t, l.allow(now))
+	utest.Assert(t, l.allow(now))
+	utest.Assert(t, !l.allow(now))
+	now += int64(50 * time.Millisecond)
+	utest.Assert(t, !l.allow(now))
+	now += int64(50 * time.Millisecond)
+	utest.Assert(t, l.allow(now))
+	utest.Assert(t, !l.allow(now))
+	now += int64(time.Hour)
+	utest.Assert(t, l.allow(now))
+	utest.Assert(t, l.allow(now))
+	utest.Assert(t, !l.allow(now))
+}
+
+func Test_Option_WithClassCapFallback(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithClassCapFallback())
+	var mems [][]byte
+	for i := 0; i < 8; i++ {
+		mems = append(mems, pool.Alloc(100))
+	}
+	mem := pool.Alloc(100)
+	utest.EqualNow(t, len(mem), 100)
+	utest.EqualNow(t, cap(mem), 128)
+	utest.EqualNow(t, pool.classForPointer(pointerOf(mem)), -1)
+	utest.Assert(t, !pool.Recycle(mem))
+
+	for _, mem := range mems {
+		utest.Assert(t, pool.Recycle(mem))
+	}
+	mem = pool.Alloc(2000)
+	utest.EqualNow(t, cap(mem), 2000)
+	utest.Assert(t, !pool.Recycle(mem))
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_Option_WithAllocInterceptor(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithAllocInterceptor(func(size int) (int, bool) {
+		switch {
+		case size == 200:
+			return 0, false
+		case size < 128:
+			return 600, true
+		}
+		return size, true
+	}))
+	mem := pool.Alloc(200)
+	utest.EqualNow(t, len(mem), 200)
+	utest.EqualNow(t, pool.classForPointer(pointerOf(mem)), -1)
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(1))
+
+	mem = pool.Alloc(100)
+	utest.EqualNow(t, len(mem), 100)
+	utest.EqualNow(t, cap(mem), 1024)
+	pool.Free(mem)
+
+	mem = pool.Alloc(300)
+	utest.EqualNow(t, cap(mem), 512)
+	pool.Free(mem)
+	utest.EqualNow(t, pool.Stats().Hits, uint64(2))
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+}
+
+func Test_Option_WithMaxPages(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithMaxPages(3))
+	var mems [][]byte
+	for i := 0; i < 24; i++ {
+		mems = append(mems, pool.Alloc(100))
+	}
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(0))
+	utest.EqualNow(t, pool.ChunkCounts()[0], 24)
+	mem := pool.Alloc(100)
+	utest.EqualNow(t, pool.classForPointer(pointerOf(mem)), -1)
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(1))
+	for _, mem := range mems {
+		pool.Free(mem)
+	}
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	pool = NewAtomPool(128, 1024, 2, 1024, WithMaxPages(3), WithFIFO())
+	for i := 0; i < 9; i++ {
+		pool.Alloc(100)
+	}
+	utest.EqualNow(t, pool.ChunkCounts()[0], 8)
+}
+
+func Test_Option_WithMaxPages_Close(t *testing.T) {
+	// Close does nothing for the pool without WithMmap, the classes still grow.
+	pool := NewAtomPool(128, 1024, 2, 1024, WithMaxPages(2))
+	utest.IsNilNow(t, pool.Close())
+	for i := 0; i < 16; i++ {
+		pool.Alloc(100)
+	}
+	utest.EqualNow(t, pool.ChunkCounts()[0], 16)
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(0))
+}
+
+func Test_Option_WithMaxPages_Concurrent(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithMaxPages(4))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var mems [][]byte
+			for j := 0; j < 4; j++ {
+				mems = append(mems, pool.Alloc(128))
+			}
+			for _, mem := range mems {
+				pool.Free(mem)
+			}
+		}()
+	}
+	wg.Wait()
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.Assert(t, pool.ChunkCounts()[0] <= 32)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_WithZeroing(t *testing.T) {
+	for _, policy := range []ZeroPolicy{ZeroOnAlloc, ZeroOnFree} {
+		pool := NewAtomPool(128, 1024, 2, 1024, WithZeroing(policy))
+		mem := pool.Alloc(100)
+		for i := range mem {
+			mem[i] = 0xff
+		}
+		pool.Free(mem)
+		if policy == ZeroOnFree {
+			utest.EqualNow(t, pool.classes[0].chunkAt(0).mem[0], byte(0))
+		}
+		mem = pool.Alloc(128)
+		for i := range mem {
+			utest.EqualNow(t, mem[i], byte(0))
+		}
+		pool.Free(mem)
+		utest.EqualNow(t, pool.Config().Zeroing, policy)
+	}
+
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(100)
+	mem[0] = 0xff
+	pool.Free(mem)
+	utest.EqualNow(t, pool.Alloc(100)[0], byte(0xff))
+}
@@ -0,0 +1,63 @@
+package slab
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RateLimit is a limit of WithRateLimit, it's attached to the class after the classes created.
+type RateLimit struct {
+	ClassSize int
+	PerSecond float64
+	Burst     int
+}
+
+// limiter is a lock-free token bucket in the form of GCRA, only the time the bucket is full again is stored.
+// A token is taken by moving tat one interval later, it's refused when tat would be more than burst intervals ahead.
+type limiter struct {
+	tat      int64 // theoretical arrival time in nanoseconds, keep 64-bit aligned for atomic operations
+	interval int64 // nanoseconds to refill a token
+	limit    int64 // burst * interval
+}
+
+func newLimiter(perSecond float64, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	interval := int64(float64(time.Second) / perSecond)
+	if interval < 1 {
+		interval = 1
+	}
+	return &limiter{interval: interval, limit: int64(burst) * interval}
+}
+
+// allow take a token at now, it returns false if the bucket is empty.
+func (l *limiter) allow(now int64) bool {
+	for {
+		old := atomic.LoadInt64(&l.tat)
+		tat := old
+		if tat < now {
+			tat = now
+		}
+		if tat+l.interval-now > l.limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&l.tat, old, tat+l.interval) {
+			return true
+		}
+	}
+}
+
+// initLimits attach the limiters of WithRateLimit to the classes.
+func (pool *AtomPool) initLimits() {
+	for _, r := range pool.rateLimits {
+		if i := pool.classForSize(r.ClassSize); i >= 0 {
+			pool.classes[i].limit = newLimiter(r.PerSecond, r.Burst)
+		}
+	}
+}
+
+// takeToken reports whether Alloc can take a chunk from c now.
+func (c *class) takeToken() bool {
+	return c.limit == nil || c.limit.allow(time.Now().UnixNano())
+}
@@ -0,0 +1,61 @@
+package slab
+
+import (
+	"bytes"
+	"io"
+)
+
+// PooledReader reads out a pooled []byte, Close releases the []byte into its pool.
+// It's not safe for concurrent use, as bytes.Reader.
+type PooledReader struct {
+	r    *bytes.Reader
+	pool Pool
+	mem  []byte
+}
+
+var (
+	_ io.ReadCloser  = (*PooledReader)(nil)
+	_ io.ByteScanner = (*PooledReader)(nil)
+	_ io.WriterTo    = (*PooledReader)(nil)
+)
+
+// Reader returns a PooledReader reads out data, data must be alloc from the pool and not used by the caller anymore.
+// Close the reader to release data, the reader reads nothing after closed.
+func (pool *AtomPool) Reader(data []byte) *PooledReader {
+	return &PooledReader{bytes.NewReader(data), pool, data}
+}
+
+// Read implements io.Reader.
+func (r *PooledReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+// ReadByte implements io.ByteReader.
+func (r *PooledReader) ReadByte() (byte, error) {
+	return r.r.ReadByte()
+}
+
+// UnreadByte implements io.ByteScanner.
+func (r *PooledReader) UnreadByte() error {
+	return r.r.UnreadByte()
+}
+
+// WriteTo implements io.WriterTo.
+func (r *PooledReader) WriteTo(w io.Writer) (int64, error) {
+	return r.r.WriteTo(w)
+}
+
+// Len returns the number of bytes not read yet.
+func (r *PooledReader) Len() int {
+	return r.r.Len()
+}
+
+// Close release the []byte into the pool, it's a no-op if the reader is closed already.
+func (r *PooledReader) Close() error {
+	if r.mem != nil {
+		r.pool.Free(r.mem)
+		r.mem = nil
+		r.r = bytes.NewReader(nil)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package slab
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AtomPool_Reader(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(100)
+	for i := range mem {
+		mem[i] = byte(i)
+	}
+	r := pool.Reader(mem)
+	b, err := r.ReadByte()
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, b, byte(0))
+	utest.EqualNow(t, r.Len(), 99)
+
+	data, err := ioutil.ReadAll(r)
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, len(data), 99)
+	utest.EqualNow(t, data[98], byte(99))
+	utest.EqualNow(t, pool.Stats().InUse, 128)
+
+	utest.IsNilNow(t, r.Close())
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, r.Close())
+	_, err = r.Read(make([]byte, 1))
+	utest.EqualNow(t, err, io.EOF)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_Reader_WriteTo(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(5)
+	copy(mem, "hello")
+	r := pool.Reader(mem)
+	defer r.Close()
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	utest.IsNilNow(t, err)
+	utest.EqualNow(t, n, int64(5))
+	utest.EqualNow(t, buf.String(), "hello")
+}
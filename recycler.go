@@ -0,0 +1,57 @@
+package slab
+
+// Recycler is a bounded ring of []byte of the same size on top of a Pool, for producer/consumer pipelines.
+// The consumer Put the buffers back into the ring and the producer Get them again,
+// so they stay cache hot in the producer instead of going through the shared pool each time.
+type Recycler struct {
+	pool Pool
+	size int
+	ring chan []byte
+}
+
+// NewRecycler create a Recycler that keeps at most n buffers of size, the ring is filled from pool at once.
+func NewRecycler(pool Pool, size, n int) *Recycler {
+	r := &Recycler{
+		pool: pool,
+		size: size,
+		ring: make(chan []byte, n),
+	}
+	for i := 0; i < n; i++ {
+		r.ring <- pool.Alloc(size)
+	}
+	return r
+}
+
+// Get take a []byte of size from the ring, if the ring is empty Get will alloc one from the pool.
+func (r *Recycler) Get() []byte {
+	select {
+	case mem := <-r.ring:
+		return mem[:r.size]
+	default:
+		return r.pool.Alloc(r.size)
+	}
+}
+
+// Put return a []byte into the ring, if the ring is full or mem is too small it's released into the pool.
+func (r *Recycler) Put(mem []byte) {
+	if cap(mem) >= r.size {
+		select {
+		case r.ring <- mem:
+			return
+		default:
+		}
+	}
+	r.pool.Free(mem)
+}
+
+// Close release the buffers in the ring into the pool, the Recycler can still be used after Close.
+func (r *Recycler) Close() {
+	for {
+		select {
+		case mem := <-r.ring:
+			r.pool.Free(mem)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_Recycler(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	r := NewRecycler(pool, 100, 4)
+	utest.EqualNow(t, pool.Stats().Classes[0].Free, 4)
+
+	mem := r.Get()
+	utest.EqualNow(t, len(mem), 100)
+	utest.EqualNow(t, cap(mem), 128)
+	r.Put(mem)
+	utest.EqualNow(t, pool.Stats().Classes[0].Free, 4)
+
+	temp := make([][]byte, 6)
+	for i := 0; i < len(temp); i++ {
+		temp[i] = r.Get()
+	}
+	utest.EqualNow(t, pool.Stats().Classes[0].Free, 2)
+	for i := 0; i < len(temp); i++ {
+		r.Put(temp[i])
+	}
+	utest.EqualNow(t, pool.Stats().Classes[0].Free, 4)
+
+	r.Put(make([]byte, 10))
+	r.Close()
+	utest.EqualNow(t, pool.Stats().Classes[0].Free, 8)
+}
+
+func Benchmark_Recycler_GetAndPut(b *testing.B) {
+	r := NewRecycler(NewAtomPool(128, 1024, 2, 64*1024), 128, 64)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r.Put(r.Get())
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package slab
+
+import "sync/atomic"
+
+// RefBuf is a reference counted []byte alloc from a Pool,
+// the []byte is released into the pool when the last reference is released.
+// It lets many goroutines share a pooled []byte, or views of it, without copying.
+type RefBuf struct {
+	refs int32
+	pool Pool
+	mem  []byte
+}
+
+// AllocRef alloc a []byte of size from pool and wrap it in a RefBuf that has one reference.
+func (pool *AtomPool) AllocRef(size int) *RefBuf {
+	return &RefBuf{
+		refs: 1,
+		pool: pool,
+		mem:  pool.Alloc(size),
+	}
+}
+
+// Bytes returns the whole []byte, it's valid until the last reference released.
+func (b *RefBuf) Bytes() []byte {
+	return b.mem
+}
+
+// Slice add a reference and returns a view of n bytes start from off.
+// The view is valid until the reference released by Release.
+func (b *RefBuf) Slice(off, n int) []byte {
+	b.Retain()
+	return b.mem[off : off+n : off+n]
+}
+
+// Retain add a reference.
+func (b *RefBuf) Retain() {
+	if atomic.AddInt32(&b.refs, 1) <= 1 {
+		panic("slab.RefBuf: Retain after released")
+	}
+}
+
+// RetainN add n references at once, e.g. one for each writer of a broadcast, before handing the RefBuf to them.
+func (b *RefBuf) RetainN(n int) {
+	if n < 0 {
+		panic("slab.RefBuf: negative count")
+	}
+	if n > 0 && atomic.AddInt32(&b.refs, int32(n)) <= int32(n) {
+		panic("slab.RefBuf: Retain after released")
+	}
+}
+
+// Refs returns the number of references, it's only a snapshot when the RefBuf is shared.
+func (b *RefBuf) Refs() int {
+	return int(atomic.LoadInt32(&b.refs))
+}
+
+// Release remove a reference, the []byte is released into the pool when no reference left.
+func (b *RefBuf) Release() {
+	refs := atomic.AddInt32(&b.refs, -1)
+	if refs == 0 {
+		b.pool.Free(b.mem)
+		b.mem = nil
+	} else if refs < 0 {
+		panic("slab.RefBuf: Release without reference")
+	}
+}
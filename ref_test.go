@@ -0,0 +1,82 @@
+package slab
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_RefBuf_Slice(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	buf := pool.AllocRef(1000)
+	utest.EqualNow(t, len(buf.Bytes()), 1000)
+	utest.Assert(t, pool.classes[3].head == 0)
+
+	copy(buf.Bytes(), "hello world")
+	hello := buf.Slice(0, 5)
+	world := buf.Slice(6, 5)
+	utest.EqualNow(t, string(hello), "hello")
+	utest.EqualNow(t, string(world), "world")
+	utest.EqualNow(t, cap(hello), 5)
+
+	buf.Release()
+	buf.Release()
+	utest.Assert(t, pool.classes[3].head == 0)
+	buf.Release()
+	utest.Assert(t, pool.classes[3].head != 0)
+	utest.IsNilNow(t, buf.Bytes())
+
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	buf.Release()
+}
+
+func Test_RefBuf_Concurrent(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	buf := pool.AllocRef(1024)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		view := buf.Slice(i*128, 128)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range view {
+				view[j] = 1
+			}
+			buf.Release()
+		}()
+	}
+	buf.Release()
+	wg.Wait()
+	utest.Assert(t, pool.classes[3].head != 0)
+}
+
+func Test_RefBuf_RetainN(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	buf := pool.AllocRef(100)
+	copy(buf.Bytes(), "payload")
+
+	const writers = 4
+	buf.RetainN(writers)
+	utest.EqualNow(t, buf.Refs(), writers+1)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer buf.Release()
+			utest.EqualNow(t, string(buf.Bytes()[:7]), "payload")
+		}()
+	}
+	buf.Release()
+	wg.Wait()
+	utest.EqualNow(t, buf.Refs(), 0)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	buf.RetainN(2)
+}
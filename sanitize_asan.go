@@ -0,0 +1,31 @@
+//go:build asan
+// +build asan
+
+package slab
+
+/*
+#include <stddef.h>
+
+void __asan_poison_memory_region(void const volatile *addr, size_t size);
+void __asan_unpoison_memory_region(void const volatile *addr, size_t size);
+*/
+import "C"
+
+import "unsafe"
+
+// asanEnabled tells the tests that read freed chunks to skip.
+const asanEnabled = true
+
+// sanitizeAlloc unpoison the chunk so the access to it is allowed by AddressSanitizer.
+func sanitizeAlloc(mem []byte) {
+	if len(mem) > 0 {
+		C.__asan_unpoison_memory_region(unsafe.Pointer(&mem[0]), C.size_t(len(mem)))
+	}
+}
+
+// sanitizeFree poison the chunk so AddressSanitizer reports the use after free and the overrun into it.
+func sanitizeFree(mem []byte) {
+	if len(mem) > 0 {
+		C.__asan_poison_memory_region(unsafe.Pointer(&mem[0]), C.size_t(len(mem)))
+	}
+}
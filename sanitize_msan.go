@@ -0,0 +1,29 @@
+//go:build msan
+// +build msan
+
+package slab
+
+/*
+#include <stddef.h>
+
+void __msan_poison(void const volatile *addr, size_t size);
+*/
+import "C"
+
+import "unsafe"
+
+const asanEnabled = false
+
+// sanitizeAlloc mark the chunk uninitialized so MemorySanitizer reports the read of the stale data.
+func sanitizeAlloc(mem []byte) {
+	if len(mem) > 0 {
+		C.__msan_poison(unsafe.Pointer(&mem[0]), C.size_t(len(mem)))
+	}
+}
+
+// sanitizeFree mark the chunk uninitialized, MemorySanitizer doesn't track the use after free.
+func sanitizeFree(mem []byte) {
+	if len(mem) > 0 {
+		C.__msan_poison(unsafe.Pointer(&mem[0]), C.size_t(len(mem)))
+	}
+}
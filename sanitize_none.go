@@ -0,0 +1,13 @@
+//go:build !asan && !msan
+// +build !asan,!msan
+
+package slab
+
+// sanitizeAlloc is called when a chunk is taken from a free list, it's a no-op without -asan or -msan.
+func sanitizeAlloc(mem []byte) {}
+
+// sanitizeFree is called when a chunk is put into a free list, it's a no-op without -asan or -msan.
+func sanitizeFree(mem []byte) {}
+
+// asanEnabled tells the tests that read freed chunks to skip.
+const asanEnabled = false
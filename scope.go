@@ -0,0 +1,37 @@
+package slab
+
+// Scope record the []byte alloc through it and release them all at once by Release.
+// A []byte alloc through a Scope can also be released by Free, Release skip it then.
+// A Scope is not safe for concurrent use, and it can be used again after Release.
+type Scope struct {
+	pool *AtomPool
+	mems [][]byte
+	ids  []uint64 // the track id of each []byte in mems
+}
+
+// NewScope create a Scope that alloc from pool.
+func (pool *AtomPool) NewScope() *Scope {
+	return &Scope{pool: pool}
+}
+
+// Alloc try alloc a []byte from the pool and record it.
+func (s *Scope) Alloc(size int) []byte {
+	mem := s.pool.Alloc(size)
+	if s.pool.WouldAccept(mem) {
+		s.mems = append(s.mems, mem)
+		s.ids = append(s.ids, s.pool.track(mem, nil))
+	}
+	return mem
+}
+
+// Release release all the []byte alloc through the scope that are not released by Free yet.
+func (s *Scope) Release() {
+	for i, mem := range s.mems {
+		if s.pool.untrack(mem, s.ids[i]) {
+			s.pool.Free(mem)
+		}
+		s.mems[i] = nil
+	}
+	s.mems = s.mems[:0]
+	s.ids = s.ids[:0]
+}
@@ -0,0 +1,54 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_Scope(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	scope := pool.NewScope()
+
+	for i := 0; i < 2; i++ {
+		mem1 := scope.Alloc(128)
+		scope.Alloc(256)
+		scope.Alloc(2048)
+		utest.EqualNow(t, pool.Stats().InUse, 384)
+
+		pool.Free(mem1)
+		utest.EqualNow(t, pool.Stats().InUse, 256)
+
+		scope.Release()
+		utest.EqualNow(t, pool.Stats().InUse, 0)
+		utest.EqualNow(t, pool.tracked, int32(0))
+		utest.IsNilNow(t, pool.CheckInvariants())
+	}
+}
+
+func Test_Scope_Reuse(t *testing.T) {
+	pool := NewAtomPool(128, 128, 2, 128)
+	scope1 := pool.NewScope()
+	scope2 := pool.NewScope()
+
+	// the only chunk is freed by hand and alloc again by another scope.
+	mem := scope1.Alloc(128)
+	pool.Free(mem)
+	mem = scope2.Alloc(128)
+	scope1.Release()
+	utest.EqualNow(t, pool.Stats().InUse, 128)
+	scope2.Release()
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+}
+
+func Test_Scope_NoAllocs(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	scope := pool.NewScope()
+	allocs := testing.AllocsPerRun(100, func() {
+		for i := 0; i < 4; i++ {
+			scope.Alloc(100)
+		}
+		scope.Release()
+	})
+	utest.EqualNow(t, allocs, float64(0))
+}
@@ -0,0 +1,107 @@
+package slab
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// shard is a free list of a class split by WithShards, padded to a cache line of its own.
+type shard struct {
+	head   uint64
+	pops   uint64
+	pushes uint64
+	_      [40]byte
+}
+
+// shardHint pick a shard for the running goroutine by its stack address,
+// so a goroutine mostly frees into and allocs from the same shard and the goroutines spread over the shards.
+// It's only a hint, the stack may move or be shared by the shard with another goroutine.
+func shardHint(n int) int {
+	var marker byte
+	sp := uintptr(unsafe.Pointer(&marker))
+	return int(uint32(sp>>13)*2654435761>>16) % n
+}
+
+// spread deal the free list of a new class to its shards.
+func (c *class) spread() {
+	head := c.head
+	c.head = 0
+	for k := 0; head != 0; k++ {
+		chk := c.chunkAt(int(head>>32 - 1))
+		s := &c.shards[k%len(c.shards)]
+		next := chk.next
+		chk.next = s.head
+		s.head = head
+		head = next
+	}
+	// the tail of each shard marks itself in use by next 0, like the tail of the single free list.
+}
+
+// attachShards split a list of chunks from the chunk of index first to tail into a run for each shard,
+// so the chunks taken out of the shards by detach are spread again.
+func (c *class) attachShards(first int, tail *chunk) {
+	n := 1
+	for chk := c.chunkAt(first); chk != tail; n++ {
+		chk = c.chunkAt(int(atomic.LoadUint64(&chk.next)>>32 - 1))
+	}
+	per := (n + len(c.shards) - 1) / len(c.shards)
+	for k := 0; ; k++ {
+		begin, chk := first, c.chunkAt(first)
+		for j := 1; j < per && chk != tail; j++ {
+			chk = c.chunkAt(int(atomic.LoadUint64(&chk.next)>>32 - 1))
+		}
+		if chk == tail {
+			c.link(&c.shards[k].head, begin, tail)
+			return
+		}
+		first = int(atomic.LoadUint64(&chk.next)>>32 - 1)
+		c.link(&c.shards[k].head, begin, chk)
+	}
+}
+
+// popShards take a chunk from the shard of the goroutine, the other shards when it's empty, then the shared list.
+func (c *class) popShards() []byte {
+	n := len(c.shards)
+	start := shardHint(n)
+	for k := 0; k < n; k++ {
+		s := &c.shards[(start+k)%n]
+		if atomic.LoadUint64(&s.head) == 0 {
+			continue
+		}
+		if mem := c.popFrom(&s.head, &s.pops); mem != nil {
+			return mem
+		}
+	}
+	return c.popFrom(&c.head, &c.pops)
+}
+
+// loadPops returns the chunks taken out of all the free lists of the class.
+func (c *class) loadPops() uint64 {
+	pops := atomic.LoadUint64(&c.pops)
+	for i := range c.shards {
+		pops += atomic.LoadUint64(&c.shards[i].pops)
+	}
+	return pops
+}
+
+// loadPushes returns the chunks linked into all the free lists of the class.
+func (c *class) loadPushes() uint64 {
+	pushes := atomic.LoadUint64(&c.pushes)
+	for i := range c.shards {
+		pushes += atomic.LoadUint64(&c.shards[i].pushes)
+	}
+	return pushes
+}
+
+// isEmpty reports whether all the free lists of the class are empty.
+func (c *class) isEmpty() bool {
+	if atomic.LoadUint64(&c.head) != 0 {
+		return false
+	}
+	for i := range c.shards {
+		if atomic.LoadUint64(&c.shards[i].head) != 0 {
+			return false
+		}
+	}
+	return true
+}
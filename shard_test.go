@@ -0,0 +1,110 @@
+package slab
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_WithShards(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithShards(4))
+	utest.EqualNow(t, len(pool.classes[0].shards), 4)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	// every chunk is reachable whatever shard it's in.
+	mems := make([][]byte, 8)
+	for i := range mems {
+		mems[i] = pool.Alloc(128)
+		utest.Assert(t, pool.classForPointer(pointerOf(mems[i])) >= 0)
+	}
+	utest.Assert(t, pool.classForPointer(pointerOf(pool.Alloc(128))) < 0)
+	utest.EqualNow(t, pool.Stats().Classes[0].Free, 0)
+
+	for _, mem := range mems {
+		pool.Free(mem)
+	}
+	utest.IsNilNow(t, pool.CheckInvariants())
+	utest.EqualNow(t, pool.Stats().Classes[0].Free, pool.Stats().Classes[0].Chunks)
+
+	pool.Clear()
+	utest.IsNilNow(t, pool.CheckInvariants())
+	for i := range mems {
+		mems[i] = pool.Alloc(128)
+		utest.Assert(t, pool.classForPointer(pointerOf(mems[i])) >= 0)
+	}
+
+	// the expanded chunks are shared by all the shards.
+	utest.IsNilNow(t, pool.Expand(128, 8))
+	mem := pool.Alloc(128)
+	utest.Assert(t, pool.classForPointer(pointerOf(mem)) >= 0)
+	pool.Free(mem)
+	for _, mem := range mems {
+		pool.Free(mem)
+	}
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	utest.EqualNow(t, len(NewAtomPool(128, 1024, 2, 1024, WithShards(4), WithFIFO()).classes[0].shards), 0)
+	utest.EqualNow(t, len(NewAtomPool(128, 1024, 2, 1024, WithShards(1)).classes[0].shards), 0)
+}
+
+func Test_WithShards_Concurrent(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 4096, WithShards(4))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				a := pool.Alloc(128)
+				b := pool.Alloc(128)
+				pool.Free(a)
+				pool.Free(b)
+			}
+		}()
+	}
+	wg.Wait()
+	utest.IsNilNow(t, pool.CheckInvariants())
+	utest.EqualNow(t, pool.Stats().Classes[0].Free, pool.Stats().Classes[0].Chunks)
+}
+
+func Benchmark_AtomPool_AllocAndFree_Shards(b *testing.B) {
+	pool := NewAtomPool(128, 1024, 2, 64*1024, WithShards(8))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Free(pool.Alloc(128))
+		}
+	})
+}
+
+// shardFree returns the number of free chunks on each shard of class c, the free lists must not be in use.
+func shardFree(c *class) []int {
+	free := make([]int, len(c.shards))
+	for k := range c.shards {
+		for i := c.shards[k].head >> 32; i != 0; i = c.chunkAt(int(i-1)).next >> 32 {
+			free[k]++
+		}
+	}
+	return free
+}
+
+func Test_WithShards_Shrink(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithShards(4), WithMaxPages(2))
+	c := &pool.classes[0]
+	mems := make([][]byte, 16)
+	for i := range mems {
+		mems[i] = pool.Alloc(128)
+	}
+	for _, mem := range mems {
+		pool.Free(mem)
+	}
+	pool.Shrink()
+	utest.DeepEqualNow(t, shardFree(c), []int{2, 2, 2, 2})
+	utest.EqualNow(t, c.head, uint64(0))
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	pool.Clear()
+	utest.DeepEqualNow(t, shardFree(c), []int{2, 2, 2, 2})
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
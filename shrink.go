@@ -0,0 +1,151 @@
+package slab
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// releasedChunk stands for the chunks of the pages released by Shrink,
+// for the Pop that loaded a head of a released chunk before it's released, its CAS fails.
+var releasedChunk chunk
+
+// Shrink release the extra pages that all their chunks are free, the first page of each class is always kept warm.
+// The pages are released from the last one back, a page in use keeps the pages added before it.
+// The pages of WithMmap are unmapped, the others are left to the garbage collector.
+// It returns the size of the pages released, it does nothing for the FIFO classes of WithFIFO,
+// or the pages of WithGuardPages and WithNUMANode which are never unmapped.
+// Like Clear, the class being shrunk makes new []byte for Alloc in the meantime.
+func (pool *AtomPool) Shrink() int {
+	total := 0
+	for i := 0; i < len(pool.classes); i++ {
+		released, _ := pool.shrink(&pool.classes[i], -1)
+		total += released
+	}
+	if grown := pool.grownPool(); grown != nil {
+		total += grown.Shrink()
+	}
+	return total
+}
+
+// ReclaimIdle start a goroutine to release the extra pages that are idle for the period, call stop to stop it.
+// A page is idle when it's at the end of its class and all its chunks are free at two checks in a row,
+// and no page is added to the class between them. The checks run every period.
+// The pages are released as Shrink.
+func (pool *AtomPool) ReclaimIdle(period time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		// the free pages and all the pages of each class at the last check.
+		freePages := make(map[*class]int)
+		pages := make(map[*class]int)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			for p := pool; p != nil; p = p.grownPool() {
+				for i := 0; i < len(p.classes); i++ {
+					c := &p.classes[i]
+					limit := 0
+					if n, ok := pages[c]; ok && n == len(c.extraPages()) {
+						limit = freePages[c]
+					}
+					_, freePages[c] = p.shrink(c, limit)
+					pages[c] = len(c.extraPages())
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+// shrink release up to limit of the free extra pages at the end of class c, or all of them if limit < 0.
+// It returns the size of the pages released and the number of free pages left at the end.
+func (pool *AtomPool) shrink(c *class, limit int) (released, left int) {
+	if c.fifo != nil || pool.guardPages || pool.numaNode > 0 {
+		return 0, 0
+	}
+	pool.expandMu.Lock()
+	defer pool.expandMu.Unlock()
+	pages := c.extraPages()
+	if len(pages) == 0 {
+		return 0, 0
+	}
+
+	old := c.detach()
+	perPage := len(c.chunks)
+	freeChunks := c.freePerPage(old, len(pages)+1)
+	free := 0
+	for free < len(pages) && freeChunks[len(pages)-free] == perPage {
+		free++
+	}
+	n := free
+	if limit >= 0 && n > limit {
+		n = limit
+	}
+
+	keep := len(pages) - n
+	if n > 0 {
+		kept := make([]*extraPage, keep)
+		copy(kept, pages)
+		for _, p := range pages[keep:] {
+			// the pages added later start above every tag of the released chunks, so a Pop still holding
+			// the index and the tag of a released chunk never matches a new chunk of the same index.
+			for j := range p.chunks {
+				if aba := p.chunks[j].aba; int32(aba-pool.pageGen) >= 0 {
+					pool.pageGen = aba + 1
+				}
+			}
+			pool.unmapPage(p.mem)
+			released += len(p.mem)
+		}
+		atomic.StorePointer(&c.extra, unsafe.Pointer(&kept))
+	}
+
+	// link the chunks of the kept pages back in the same order.
+	first := -1
+	var tail *chunk
+	limitIndex := (keep + 1) * perPage
+	for i := old; i>>32 != 0; {
+		idx := int(i>>32 - 1)
+		var chk *chunk
+		if idx < perPage {
+			chk = &c.chunks[idx]
+		} else {
+			chk = &pages[idx/perPage-1].chunks[idx%perPage]
+		}
+		i = atomic.LoadUint64(&chk.next)
+		if idx >= limitIndex {
+			continue
+		}
+		if tail == nil {
+			first = idx
+		} else {
+			atomic.StoreUint64(&tail.next, uint64(idx+1)<<32+uint64(chk.aba))
+		}
+		tail = chk
+	}
+	if tail != nil {
+		atomic.StoreUint64(&tail.next, 0)
+		c.attach(first, tail)
+	}
+	return released, free - n
+}
+
+// unmapPage unmap a page of WithMmap, it does nothing for the page not mapped.
+func (pool *AtomPool) unmapPage(page []byte) {
+	pool.mapMu.Lock()
+	defer pool.mapMu.Unlock()
+	for i, mapped := range pool.mapped {
+		if pointerOf(mapped) == pointerOf(page) {
+			munmapPage(mapped)
+			pool.mapped = append(pool.mapped[:i], pool.mapped[i+1:]...)
+			return
+		}
+	}
+}
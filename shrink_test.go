@@ -0,0 +1,90 @@
+package slab
+
+import (
+	"testing"
+	"time"
+
+	"github.com/funny/utest"
+)
+
+func Test_AtomPool_Shrink(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	utest.EqualNow(t, pool.Shrink(), 0)
+	utest.IsNilNow(t, pool.Expand(128, 24))
+	c := &pool.classes[0]
+	utest.EqualNow(t, c.chunkCount(), 32)
+
+	// a chunk in use in the third page keeps the first three pages.
+	mems := make([][]byte, 32)
+	var last []byte
+	for i := range mems {
+		mems[i] = pool.Alloc(128)
+		if c.chunkIndex(pointerOf(mems[i]))/8 == 2 {
+			last = mems[i]
+		}
+	}
+	for _, mem := range mems {
+		if pointerOf(mem) != pointerOf(last) {
+			pool.Free(mem)
+		}
+	}
+	utest.EqualNow(t, pool.Shrink(), 1024)
+	utest.EqualNow(t, c.chunkCount(), 24)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	pool.Free(last)
+	utest.EqualNow(t, pool.Shrink(), 2048)
+	utest.EqualNow(t, c.chunkCount(), 8)
+	utest.EqualNow(t, pool.Stats().Classes[0].Free, 8)
+	utest.IsNilNow(t, pool.CheckInvariants())
+
+	// the class can grow again.
+	utest.IsNilNow(t, pool.Expand(128, 8))
+	for i := 0; i < 16; i++ {
+		mems[i] = pool.Alloc(128)
+		utest.Assert(t, pool.classForPointer(pointerOf(mems[i])) >= 0)
+	}
+	for _, mem := range mems[:16] {
+		pool.Free(mem)
+	}
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_Shrink_Tags(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	utest.IsNilNow(t, pool.Expand(128, 8))
+	c := &pool.classes[0]
+	// push the chunks of the extra page many times, their tags go far above the tags of a new page.
+	mems := make([][]byte, 16)
+	for k := 0; k < 100; k++ {
+		for i := range mems {
+			mems[i] = pool.Alloc(128)
+		}
+		for _, mem := range mems {
+			pool.Free(mem)
+		}
+	}
+	var released uint32
+	for _, chk := range c.extraPages()[0].chunks {
+		if chk.aba > released {
+			released = chk.aba
+		}
+	}
+	utest.EqualNow(t, pool.Shrink(), 1024)
+	utest.IsNilNow(t, pool.Expand(128, 8))
+	for _, chk := range c.extraPages()[0].chunks {
+		utest.Assert(t, chk.aba > released, chk.aba, released)
+	}
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_ReclaimIdle(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	utest.IsNilNow(t, pool.Expand(128, 8))
+	stop := pool.ReclaimIdle(time.Millisecond)
+	defer stop()
+	for i := 0; i < 1000 && pool.classes[0].chunkCount() > 8; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	utest.EqualNow(t, pool.classes[0].chunkCount(), 8)
+}
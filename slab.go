@@ -1,10 +1,16 @@
 package slab
 
+import "fmt"
+
+// Pool is the interface of the memory pools, they are interchangeable at the call sites.
+// Alloc returns a []byte of size, Free release a []byte alloc from the same pool,
+// a []byte the pool doesn't own is left to the garbage collector.
 type Pool interface {
 	Alloc(int) []byte
 	Free([]byte)
 }
 
+// NoPool alloc every []byte by make() and never reuse them, it's the baseline to compare the pools with.
 type NoPool struct{}
 
 func (p *NoPool) Alloc(size int) []byte {
@@ -16,4 +22,36 @@ func (p *NoPool) Free(_ []byte) {}
 var _ Pool = (*NoPool)(nil)
 var _ Pool = (*ChanPool)(nil)
 var _ Pool = (*SyncPool)(nil)
+var _ Pool = (*LockPool)(nil)
 var _ Pool = (*AtomPool)(nil)
+var _ Pool = (*UnsafePool)(nil)
+var _ Pool = (*HybridPool)(nil)
+var _ Pool = (*NUMAPool)(nil)
+
+// NewPool create a Pool of kind, so the implementation can be switched by configuration:
+// "atom" for AtomPool, "hybrid" for HybridPool on an AtomPool, "chan" for ChanPool, "lock" for LockPool,
+// "sync" for SyncPool, which ignores pageSize, "unsafe" for UnsafePool, which is not safe for concurrent use,
+// and "none" for NoPool. The other arguments are the ones of NewAtomPool.
+//
+// The lock-free AtomPool fits most workloads. SyncPool never runs out and gives the memory back to GC,
+// so it fits the bursty workloads, at the cost of an allocation for each Free. ChanPool and LockPool are
+// bounded like AtomPool and simpler, they fit few goroutines. See the Benchmark_Pool_ benchmarks.
+func NewPool(kind string, minSize, maxSize, factor, pageSize int) (Pool, error) {
+	switch kind {
+	case "atom":
+		return NewAtomPool(minSize, maxSize, factor, pageSize), nil
+	case "hybrid":
+		return NewHybridPool(NewAtomPool(minSize, maxSize, factor, pageSize)), nil
+	case "chan":
+		return NewChanPool(minSize, maxSize, factor, pageSize), nil
+	case "lock":
+		return NewLockPool(minSize, maxSize, factor, pageSize), nil
+	case "sync":
+		return NewSyncPool(minSize, maxSize, factor), nil
+	case "unsafe":
+		return NewUnsafePool(minSize, maxSize, factor, pageSize), nil
+	case "none":
+		return &NoPool{}, nil
+	}
+	return nil, fmt.Errorf("slab.NewPool: unknown kind %q", kind)
+}
@@ -0,0 +1,66 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+var poolKinds = []string{"atom", "hybrid", "chan", "lock", "sync", "unsafe", "none"}
+
+func Test_NewPool(t *testing.T) {
+	for _, kind := range poolKinds {
+		pool, err := NewPool(kind, 128, 1024, 2, 64*1024)
+		utest.IsNilNow(t, err)
+		mem := pool.Alloc(100)
+		utest.EqualNow(t, len(mem), 100)
+		pool.Free(mem)
+		big := pool.Alloc(2000)
+		utest.EqualNow(t, len(big), 2000)
+		pool.Free(big)
+	}
+	_, err := NewPool("slab", 128, 1024, 2, 64*1024)
+	utest.NotNilNow(t, err)
+}
+
+// benchmarkSteady alloc and free one []byte at a time from many goroutines.
+func benchmarkSteady(b *testing.B, kind string) {
+	pool, _ := NewPool(kind, 128, 64*1024, 2, 1024*1024)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Free(pool.Alloc(512))
+		}
+	})
+}
+
+// benchmarkBurst alloc 64 []byte before free them from many goroutines.
+func benchmarkBurst(b *testing.B, kind string) {
+	pool, _ := NewPool(kind, 128, 64*1024, 2, 64*1024)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		mems := make([][]byte, 64)
+		for pb.Next() {
+			for i := range mems {
+				mems[i] = pool.Alloc(512)
+			}
+			for _, mem := range mems {
+				pool.Free(mem)
+			}
+		}
+	})
+}
+
+func Benchmark_Pool_Steady_Atom(b *testing.B)   { benchmarkSteady(b, "atom") }
+func Benchmark_Pool_Steady_Hybrid(b *testing.B) { benchmarkSteady(b, "hybrid") }
+func Benchmark_Pool_Steady_Chan(b *testing.B)   { benchmarkSteady(b, "chan") }
+func Benchmark_Pool_Steady_Lock(b *testing.B)   { benchmarkSteady(b, "lock") }
+func Benchmark_Pool_Steady_Sync(b *testing.B)   { benchmarkSteady(b, "sync") }
+func Benchmark_Pool_Steady_None(b *testing.B)   { benchmarkSteady(b, "none") }
+
+func Benchmark_Pool_Burst_Atom(b *testing.B)   { benchmarkBurst(b, "atom") }
+func Benchmark_Pool_Burst_Hybrid(b *testing.B) { benchmarkBurst(b, "hybrid") }
+func Benchmark_Pool_Burst_Chan(b *testing.B)   { benchmarkBurst(b, "chan") }
+func Benchmark_Pool_Burst_Lock(b *testing.B)   { benchmarkBurst(b, "lock") }
+func Benchmark_Pool_Burst_Sync(b *testing.B)   { benchmarkBurst(b, "sync") }
+func Benchmark_Pool_Burst_None(b *testing.B)   { benchmarkBurst(b, "none") }
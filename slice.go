@@ -0,0 +1,70 @@
+//go:build go1.18
+// +build go1.18
+
+package slab
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// AllocSlice alloc a []T of length n from the pool, the elements are zero only if the chunk is.
+// The first element is aligned as T requires, and the capacity covers the rest of the chunk.
+// T must not contain pointers, because the garbage collector never scans the pool memory.
+// Release it with FreeSlice.
+func AllocSlice[T any](pool *AtomPool, n int) []T {
+	var zero T
+	t := reflect.TypeOf(&zero).Elem()
+	if hasPointers(t) {
+		panic("slab.AllocSlice: " + t.String() + " contains pointers")
+	}
+	size, align := int(unsafe.Sizeof(zero)), int(unsafe.Alignof(zero))
+	if size == 0 || n <= 0 || n > pool.maxSize/size {
+		return make([]T, n)
+	}
+
+	mem := pool.Alloc(n * size)
+	off := alignOffset(mem, align)
+	if off+n*size > cap(mem) {
+		pool.Free(mem)
+		mem = pool.Alloc(n*size + align - 1)
+		off = alignOffset(mem, align)
+	}
+	if pool.classForPointer(pointerOf(mem)) < 0 {
+		pool.Free(mem)
+		return make([]T, n)
+	}
+	mem = mem[:cap(mem)]
+	return unsafe.Slice((*T)(unsafe.Pointer(&mem[off])), (len(mem)-off)/size)[:n]
+}
+
+// FreeSlice release a []T that alloc from AllocSlice, the chunk is found by the address of the elements.
+func FreeSlice[T any](pool *AtomPool, s []T) {
+	if cap(s) == 0 || unsafe.Sizeof(s[:1][0]) == 0 {
+		return
+	}
+	ptr := uintptr(unsafe.Pointer(&s[:1][0]))
+	if i := pool.classForPointer(ptr); i >= 0 {
+		c := &pool.classes[i]
+		pool.Free(c.chunkAt(c.chunkIndex(ptr)).mem)
+	}
+}
+
+func hasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return t.Len() > 0 && hasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+//go:build go1.18
+// +build go1.18
+
+package slab
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/funny/utest"
+)
+
+type testElem struct {
+	A uint64
+	B uint8
+}
+
+func testAllocSlice[T any](t *testing.T, pool *AtomPool, n int) {
+	s := AllocSlice[T](pool, n)
+	utest.EqualNow(t, len(s), n)
+	var zero T
+	ptr := uintptr(unsafe.Pointer(&s[0]))
+	utest.EqualNow(t, ptr%unsafe.Alignof(zero), uintptr(0))
+	utest.Assert(t, pool.classForPointer(ptr) >= 0)
+	for i := range s {
+		s[i] = zero
+	}
+	FreeSlice(pool, s)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AllocSlice(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 4096)
+	testAllocSlice[uint8](t, pool, 100)
+	testAllocSlice[uint16](t, pool, 100)
+	testAllocSlice[uint32](t, pool, 100)
+	testAllocSlice[uint64](t, pool, 100)
+	testAllocSlice[[3]byte](t, pool, 100)
+	testAllocSlice[testElem](t, pool, 50)
+	testAllocSlice[complex128](t, pool, 64)
+}
+
+func Test_AllocSlice_Misaligned(t *testing.T) {
+	// the chunk size 100 leaves odd chunks misaligned for uint64.
+	pool := NewAtomPoolN(25, 100, 3, 1000)
+	var temp [][]uint64
+	for i := 0; i < 10; i++ {
+		s := AllocSlice[uint64](pool, 11)
+		utest.EqualNow(t, uintptr(unsafe.Pointer(&s[0]))%unsafe.Alignof(s[0]), uintptr(0))
+		temp = append(temp, s)
+	}
+	for _, s := range temp {
+		FreeSlice(pool, s)
+	}
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AllocSlice_Fallback(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	s := AllocSlice[uint64](pool, 1000)
+	utest.EqualNow(t, len(s), 1000)
+	FreeSlice(pool, s)
+
+	e := AllocSlice[struct{}](pool, 10)
+	utest.EqualNow(t, len(e), 10)
+	FreeSlice(pool, e)
+
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	AllocSlice[*int](pool, 10)
+}
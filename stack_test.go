@@ -0,0 +1,201 @@
+package slab
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+// stack is a bounded lock-free stack of chunk indexes in a contiguous slice.
+// Push and Pop reserve a slot by moving top, then fill or drain it. A slot is 0 when empty and holds index+1
+// when full, so a Pop that reserved a slot before the Push filling it waits for the value, and a Push waits for
+// the Pop still draining the slot. The reservations of a slot always alternate between Push and Pop,
+// so every fill is drained exactly once.
+//
+// It's the array variant of the free list benchmarked against the linked free list by Benchmark_FreeList_*, it saves loading
+// chunk.next in Pop but needs two atomic operations per Push and Pop instead of one CAS,
+// and it comes out about 30% slower both in the parallel and in the burst benchmarks. See chunk.
+type stack struct {
+	top   uint64
+	slots []uint64
+}
+
+// newStack create a stack can hold n chunk indexes.
+func newStack(n int) *stack {
+	return &stack{slots: make([]uint64, n)}
+}
+
+// Push put a chunk index on top of the stack, the stack must have room for it.
+func (s *stack) Push(index uint64) {
+	slot := &s.slots[atomic.AddUint64(&s.top, 1)-1]
+	for !atomic.CompareAndSwapUint64(slot, 0, index+1) {
+		runtime.Gosched()
+	}
+}
+
+// Pop take the chunk index on top of the stack, it returns false if the stack is empty.
+func (s *stack) Pop() (uint64, bool) {
+	for {
+		top := atomic.LoadUint64(&s.top)
+		if top == 0 {
+			return 0, false
+		}
+		if atomic.CompareAndSwapUint64(&s.top, top, top-1) {
+			slot := &s.slots[top-1]
+			for {
+				if v := atomic.LoadUint64(slot); v != 0 && atomic.CompareAndSwapUint64(slot, v, 0) {
+					return v - 1, true
+				}
+				runtime.Gosched()
+			}
+		}
+		runtime.Gosched()
+	}
+}
+
+// Len returns the number of chunk indexes in the stack, it's approximate while the stack is in use.
+func (s *stack) Len() int {
+	return int(atomic.LoadUint64(&s.top))
+}
+
+func Test_Stack(t *testing.T) {
+	s := newStack(4)
+	_, ok := s.Pop()
+	utest.Assert(t, !ok)
+	s.Push(1)
+	s.Push(2)
+	utest.EqualNow(t, s.Len(), 2)
+	i, ok := s.Pop()
+	utest.Assert(t, ok)
+	utest.EqualNow(t, i, uint64(2))
+	i, _ = s.Pop()
+	utest.EqualNow(t, i, uint64(1))
+	utest.EqualNow(t, s.Len(), 0)
+}
+
+func Test_Stack_Concurrent(t *testing.T) {
+	const n = 64
+	s := newStack(n)
+	for i := uint64(0); i < n; i++ {
+		s.Push(i)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 10000; k++ {
+				if i, ok := s.Pop(); ok {
+					s.Push(i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make([]bool, n)
+	for i, ok := s.Pop(); ok; i, ok = s.Pop() {
+		utest.Assert(t, !seen[i], "index popped twice", i)
+		seen[i] = true
+	}
+	for i := range seen {
+		utest.Assert(t, seen[i], "index lost", i)
+	}
+}
+
+// Benchmark_FreeList_* compare the linked free list of a class with the array stack of the same chunks.
+
+func Benchmark_FreeList_Linked(b *testing.B) {
+	pool := NewAtomPool(128, 128, 2, 1024*1024)
+	c := &pool.classes[0]
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if mem := c.Pop(); mem != nil {
+				c.Push(mem)
+			}
+		}
+	})
+}
+
+func Benchmark_FreeList_Stack(b *testing.B) {
+	pool := NewAtomPool(128, 128, 2, 1024*1024)
+	c, s := benchStack(pool)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if mem := stackPop(c, s); mem != nil {
+				stackPush(c, s, mem)
+			}
+		}
+	})
+}
+
+func Benchmark_FreeList_Linked_Burst(b *testing.B) {
+	pool := NewAtomPool(128, 128, 2, 1024*1024)
+	c := &pool.classes[0]
+	temp := make([][]byte, 256)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range temp {
+			temp[i] = c.Pop()
+		}
+		for i := range temp {
+			c.Push(temp[i])
+		}
+	}
+}
+
+func Benchmark_FreeList_Stack_Burst(b *testing.B) {
+	pool := NewAtomPool(128, 128, 2, 1024*1024)
+	c, s := benchStack(pool)
+	temp := make([][]byte, 256)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range temp {
+			temp[i] = stackPop(c, s)
+		}
+		for i := range temp {
+			stackPush(c, s, temp[i])
+		}
+	}
+}
+
+// stackPop and stackPush do the bookkeeping of class.Pop and class.Push around the stack,
+// so the benchmarks only differ in the free list.
+func stackPop(c *class, s *stack) []byte {
+	i, ok := s.Pop()
+	if !ok {
+		return nil
+	}
+	chk := c.chunkAt(int(i))
+	atomic.StoreUint64(&chk.next, 0)
+	atomic.AddUint64(&c.pops, 1)
+	sanitizeAlloc(chk.mem)
+	return chk.mem
+}
+
+func stackPush(c *class, s *stack, mem []byte) {
+	ptr := pointerOf(mem)
+	i := c.chunkIndex(ptr)
+	chk := c.chunkAt(i)
+	c.release(chk, ptr)
+	atomic.StoreUint64(&chk.next, fifoQueued)
+	s.Push(uint64(i))
+	atomic.AddUint64(&c.pushes, 1)
+}
+
+// benchStack returns the first class of pool and a stack of all its chunks, in the order of the free list.
+func benchStack(pool *AtomPool) (*class, *stack) {
+	c := &pool.classes[0]
+	s := newStack(len(c.chunks))
+	for i := len(c.chunks) - 1; i >= 0; i-- {
+		c.chunks[i].next = fifoQueued
+		s.Push(uint64(i))
+	}
+	c.head = 0
+	return c, s
+}
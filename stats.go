@@ -0,0 +1,193 @@
+package slab
+
+import "sync/atomic"
+
+// Stats is a snapshot of the counters of AtomPool.
+// The counters are read one by one while the pool is in use, so they may not exact match each other.
+type Stats struct {
+	Allocs    uint64 // number of []byte returned by Alloc
+	Hits      uint64 // Allocs served by the slab classes
+	Fallbacks uint64 // Allocs made by make()
+	Frees     uint64 // number of []byte released into the slab classes
+	BadFrees  uint64 // Frees panicked for a bad chunk or a double free
+	InUse     int    // memory size of the chunks in use
+	Classes   []ClassStats
+
+	// CAS counters of the free lists, they are zero unless WithCASStats.
+	CASAttempts  uint64
+	CASSuccesses uint64
+}
+
+// ClassStats is a snapshot of the counters of a slab class.
+type ClassStats struct {
+	Size   int    // chunk size
+	Chunks int    // number of chunks
+	Free   int    // number of free chunks
+	Allocs uint64 // chunks taken from the class
+	Misses uint64 // Allocs of the class made by make() since the class is empty, they are counted by Fallbacks too
+	Frees  uint64 // chunks released into the class
+
+	BadFrees uint64 // Frees panicked for a bad chunk or a double free
+
+	CASAttempts  uint64
+	CASSuccesses uint64
+}
+
+// Stats returns a snapshot of the pool counters.
+// Each counter is loaded atomically so it's never torn, but the counters are not loaded at the same instant.
+func (pool *AtomPool) Stats() Stats {
+	stats := Stats{
+		Fallbacks: atomic.LoadUint64(&pool.fallbacks),
+		Classes:   make([]ClassStats, len(pool.classes)),
+	}
+	for i := 0; i < len(pool.classes); i++ {
+		cs := pool.classes[i].Stats()
+		stats.Hits += cs.Allocs
+		stats.Frees += cs.Frees
+		stats.BadFrees += cs.BadFrees
+		stats.InUse += (cs.Chunks - cs.Free) * cs.Size
+		stats.CASAttempts += cs.CASAttempts
+		stats.CASSuccesses += cs.CASSuccesses
+		stats.Classes[i] = cs
+	}
+	if grown := pool.grownPool(); grown != nil {
+		gs := grown.Stats()
+		stats.Fallbacks += gs.Fallbacks
+		stats.Hits += gs.Hits
+		stats.Frees += gs.Frees
+		stats.BadFrees += gs.BadFrees
+		stats.InUse += gs.InUse
+		stats.CASAttempts += gs.CASAttempts
+		stats.CASSuccesses += gs.CASSuccesses
+		stats.Classes = append(stats.Classes, gs.Classes...)
+	}
+	stats.Allocs = stats.Hits + stats.Fallbacks
+	return stats
+}
+
+// Utilization returns the ratio of the chunks in use to all the chunks of the class.
+func (cs ClassStats) Utilization() float64 {
+	if cs.Chunks == 0 {
+		return 0
+	}
+	return float64(cs.Chunks-cs.Free) / float64(cs.Chunks)
+}
+
+// Snapshot is Stats, the name tells what it returns: a value that is not changed by the pool afterwards.
+func (pool *AtomPool) Snapshot() Stats {
+	return pool.Stats()
+}
+
+// ResetStats zero the counters of Stats, except Free and InUse that are the state of the pool.
+// The counters are zeroed one by one, an Alloc or Free running at the same time may be counted or not.
+func (pool *AtomPool) ResetStats() {
+	atomic.StoreUint64(&pool.fallbacks, 0)
+	for i := 0; i < len(pool.classes); i++ {
+		c := &pool.classes[i]
+		// pops and pushes also count the chunks in use, so they are kept and the values at reset subtracted.
+		atomic.StoreUint64(&c.resetPushes, c.loadPushes())
+		atomic.StoreUint64(&c.resetPops, c.loadPops())
+		atomic.StoreUint64(&c.casAttempts, 0)
+		atomic.StoreUint64(&c.casSuccesses, 0)
+		atomic.StoreUint64(&c.misses, 0)
+		atomic.StoreUint64(&c.badFrees, 0)
+	}
+	if grown := pool.grownPool(); grown != nil {
+		grown.ResetStats()
+	}
+}
+
+func (c *class) Stats() ClassStats {
+	pops := c.loadPops()
+	pushes := c.loadPushes()
+	return ClassStats{
+		Size:   c.size,
+		Chunks: c.chunkCount(),
+		Free:   c.free(pops, pushes),
+		Allocs: since(pops, atomic.LoadUint64(&c.resetPops)),
+		Misses: atomic.LoadUint64(&c.misses),
+		Frees:  since(pushes, atomic.LoadUint64(&c.resetPushes)),
+
+		BadFrees: atomic.LoadUint64(&c.badFrees),
+
+		CASAttempts:  atomic.LoadUint64(&c.casAttempts),
+		CASSuccesses: atomic.LoadUint64(&c.casSuccesses),
+	}
+}
+
+// since returns counter minus its value at the last ResetStats,
+// counter may be loaded before a ResetStats running at the same time, then it's 0.
+func since(counter, reset uint64) uint64 {
+	if counter < reset {
+		return 0
+	}
+	return counter - reset
+}
+
+// free returns the number of free chunks by pops and pushes counters.
+func (c *class) free(pops, pushes uint64) int {
+	chunks := c.chunkCount()
+	inUse := int64(pops - pushes)
+	if inUse < 0 {
+		// pops is loaded before pushes, a chunk popped and pushed in between is counted by pushes only.
+		inUse = 0
+	}
+	if inUse > int64(chunks) {
+		inUse = int64(chunks)
+	}
+	return chunks - int(inUse)
+}
+
+// ClassFragmentation tells how the chunks in use of a slab class spread over its pages.
+// The ratio LivePages/Pages close to 1 with few chunks in use means the class is fragmented.
+type ClassFragmentation struct {
+	Size      int // chunk size
+	Pages     int // number of pages
+	LivePages int // pages that have at least one chunk in use
+	FreePages int // pages that have no chunk in use
+}
+
+// Fragmentation returns the page usage of each slab class.
+// The chunks in use are counted on each page by walking the free list, a page that none of its chunks is on
+// the free list is live. Like Clear, the class being walked makes new []byte for Alloc in the meantime.
+func (pool *AtomPool) Fragmentation() []ClassFragmentation {
+	pool.expandMu.Lock()
+	defer pool.expandMu.Unlock()
+	frag := make([]ClassFragmentation, len(pool.classes))
+	for i := 0; i < len(pool.classes); i++ {
+		c := &pool.classes[i]
+		frag[i].Size = c.size
+		if len(c.chunks) == 0 {
+			continue
+		}
+		pages := c.chunkCount() / len(c.chunks)
+		frag[i].Pages = pages
+		if c.fifo != nil {
+			// the FIFO classes have a single page.
+			if c.free(c.loadPops(), c.loadPushes()) == len(c.chunks) {
+				frag[i].FreePages = 1
+			}
+		} else {
+			old := c.detach()
+			for _, n := range c.freePerPage(old, pages) {
+				if n == len(c.chunks) {
+					frag[i].FreePages++
+				}
+			}
+			if old != 0 {
+				c.attach(int(old>>32-1), c.listTail(old))
+			}
+		}
+		frag[i].LivePages = pages - frag[i].FreePages
+	}
+	return frag
+}
+
+// freePerPage returns the number of chunks of the detached free list head on each of the first pages of class c.
+func (c *class) freePerPage(head uint64, pages int) []int {
+	free := make([]int, pages)
+	for i := head >> 32; i != 0; i = atomic.LoadUint64(&c.chunkAt(int(i-1)).next) >> 32 {
+		free[(i-1)/uint64(len(c.chunks))]++
+	}
+	return free
+}
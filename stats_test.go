@@ -0,0 +1,156 @@
+package slab
+
+import (
+	"encoding/json"
+	"expvar"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_AtomPool_Stats(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem1 := pool.Alloc(100)
+	mem2 := pool.Alloc(1000)
+	mem3 := pool.Alloc(1000)
+	mem4 := pool.Alloc(2000)
+
+	stats := pool.Stats()
+	utest.EqualNow(t, stats.Allocs, uint64(4))
+	utest.EqualNow(t, stats.Hits, uint64(2))
+	utest.EqualNow(t, stats.Fallbacks, uint64(2))
+	utest.EqualNow(t, stats.Frees, uint64(0))
+	utest.EqualNow(t, stats.InUse, 128+1024)
+	utest.EqualNow(t, len(stats.Classes), 4)
+	utest.EqualNow(t, stats.Classes[0], ClassStats{Size: 128, Chunks: 8, Free: 7, Allocs: 1})
+	utest.EqualNow(t, stats.Classes[3], ClassStats{Size: 1024, Chunks: 1, Free: 0, Allocs: 1, Misses: 1})
+	utest.EqualNow(t, stats.Classes[3].Utilization(), float64(1))
+	utest.EqualNow(t, stats.Classes[0].Utilization(), 0.125)
+
+	pool.Free(mem1)
+	pool.Free(mem2)
+	pool.Free(mem3)
+	pool.Free(mem4)
+
+	stats = pool.Stats()
+	utest.EqualNow(t, stats.Frees, uint64(2))
+	utest.EqualNow(t, stats.InUse, 0)
+	utest.EqualNow(t, stats.Classes[0], ClassStats{Size: 128, Chunks: 8, Free: 8, Allocs: 1, Frees: 1})
+}
+
+func Test_AtomPool_Var(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	pool.Alloc(100)
+	var stats Stats
+	utest.IsNilNow(t, json.Unmarshal([]byte(pool.Var().String()), &stats))
+	utest.EqualNow(t, stats.Hits, uint64(1))
+	utest.EqualNow(t, stats.InUse, 128)
+
+	// the name is published once per process, so the test can run again by -count or -cpu.
+	name := "slab_test_pool_" + strconv.Itoa(int(atomic.AddInt32(&publishedVars, 1)))
+	pool.PublishExpvar(name)
+	utest.EqualNow(t, expvar.Get(name).String(), pool.Var().String())
+}
+
+var publishedVars int32
+
+func Test_AtomPool_CASStats(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	pool.Free(pool.Alloc(128))
+	stats := pool.Stats()
+	utest.EqualNow(t, stats.CASAttempts, uint64(0))
+	utest.EqualNow(t, stats.CASSuccesses, uint64(0))
+
+	pool = NewAtomPool(128, 1024, 2, 64*1024, WithCASStats())
+	pool.Free(pool.Alloc(128))
+	stats = pool.Stats()
+	utest.EqualNow(t, stats.CASAttempts, uint64(2))
+	utest.EqualNow(t, stats.CASSuccesses, uint64(2))
+	utest.EqualNow(t, stats.Classes[0].CASSuccesses, uint64(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				pool.Free(pool.Alloc(256))
+			}
+		}()
+	}
+	wg.Wait()
+	stats = pool.Stats()
+	utest.EqualNow(t, stats.Classes[1].CASSuccesses, uint64(8*1000*2))
+	utest.Assert(t, stats.Classes[1].CASAttempts >= stats.Classes[1].CASSuccesses)
+}
+
+func Test_AtomPool_Fragmentation(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	mem := pool.Alloc(128)
+	frag := pool.Fragmentation()
+	utest.EqualNow(t, len(frag), 4)
+	utest.EqualNow(t, frag[0], ClassFragmentation{Size: 128, Pages: 1, LivePages: 1})
+	utest.EqualNow(t, frag[1], ClassFragmentation{Size: 256, Pages: 1, FreePages: 1})
+
+	pool.Free(mem)
+	frag = pool.Fragmentation()
+	utest.EqualNow(t, frag[0], ClassFragmentation{Size: 128, Pages: 1, FreePages: 1})
+}
+
+func Test_AtomPool_Fragmentation_Pages(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	utest.IsNilNow(t, pool.Expand(128, 16))
+	c := &pool.classes[0]
+	var mems [][]byte
+	for i := 0; i < c.chunkCount(); i++ {
+		mems = append(mems, pool.Alloc(128))
+	}
+	frag := pool.Fragmentation()
+	utest.EqualNow(t, frag[0], ClassFragmentation{Size: 128, Pages: 3, LivePages: 3})
+
+	// keep three chunks in use, all on the first page.
+	live := 0
+	for _, mem := range mems {
+		if c.chunkIndex(pointerOf(mem)) < len(c.chunks) && live < 3 {
+			live++
+			continue
+		}
+		pool.Free(mem)
+	}
+	frag = pool.Fragmentation()
+	utest.EqualNow(t, frag[0], ClassFragmentation{Size: 128, Pages: 3, LivePages: 1, FreePages: 2})
+	utest.EqualNow(t, pool.Shrink(), 2*1024)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
+
+func Test_AtomPool_ResetStats(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithCASStats())
+	mem := pool.Alloc(128)
+	pool.Free(pool.Alloc(256))
+	pool.Alloc(2048)
+
+	stats := pool.Snapshot()
+	utest.EqualNow(t, stats.Allocs, uint64(3))
+	utest.EqualNow(t, stats.Fallbacks, uint64(1))
+	utest.EqualNow(t, stats.Frees, uint64(1))
+	utest.EqualNow(t, stats.InUse, 128)
+
+	pool.ResetStats()
+	stats = pool.Snapshot()
+	utest.EqualNow(t, stats.Allocs, uint64(0))
+	utest.EqualNow(t, stats.Hits, uint64(0))
+	utest.EqualNow(t, stats.Fallbacks, uint64(0))
+	utest.EqualNow(t, stats.Frees, uint64(0))
+	utest.EqualNow(t, stats.CASAttempts, uint64(0))
+	utest.EqualNow(t, stats.InUse, 128)
+	utest.EqualNow(t, stats.Classes[0].Free, 7)
+
+	pool.Free(mem)
+	stats = pool.Snapshot()
+	utest.EqualNow(t, stats.Frees, uint64(1))
+	utest.EqualNow(t, stats.InUse, 0)
+	utest.IsNilNow(t, pool.CheckInvariants())
+}
@@ -16,7 +16,7 @@ type SyncPool struct {
 // factor is used to control growth of chunk size.
 func NewSyncPool(minSize, maxSize, factor int) *SyncPool {
 	n := 0
-	for chunkSize := minSize; chunkSize <= maxSize; chunkSize *= factor {
+	for chunkSize := minSize; chunkSize > 0 && chunkSize <= maxSize; chunkSize = growSize(chunkSize, factor) {
 		n++
 	}
 	pool := &SyncPool{
@@ -25,7 +25,7 @@ func NewSyncPool(minSize, maxSize, factor int) *SyncPool {
 		minSize, maxSize,
 	}
 	n = 0
-	for chunkSize := minSize; chunkSize <= maxSize; chunkSize *= factor {
+	for chunkSize := minSize; chunkSize > 0 && chunkSize <= maxSize; chunkSize = growSize(chunkSize, factor) {
 		pool.classesSize[n] = chunkSize
 		pool.classes[n].New = func(size int) func() interface{} {
 			return func() interface{} {
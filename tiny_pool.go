@@ -0,0 +1,224 @@
+package slab
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// maxTinyCells is the most cells a chunk can be divided into, the cell index is packed into 16 bits of tinyBlock.state.
+const maxTinyCells = 1<<16 - 1
+
+// TinyPool serve the allocations smaller than the smallest class of a Pool.
+// It alloc chunks of chunkSize from the Pool and divide each chunk into cells of cellSize,
+// a chunk is released into the Pool when all its cells are free.
+// The cells of each chunk are linked into a lock-free free list like the chunks of a slab class,
+// only adding and releasing a chunk take a mutex.
+type TinyPool struct {
+	inUse     int64  // keep 64-bit aligned for atomic operations
+	hint      uint32 // index of the block served the last TinyAlloc
+	pool      Pool
+	cellSize  int
+	chunkSize int
+	cells     int // cells in a chunk
+
+	mu     sync.Mutex     // guard the replace of blocks
+	blocks unsafe.Pointer // *[]*tinyBlock sorted by address, replaced as a whole
+}
+
+// tinyBlock is a chunk divided into cells.
+// state packs the index+1 of the first free cell in the low 16 bits, the number of free cells in the next 16 bits,
+// and an ABA tag bumped by every change in the high 32 bits.
+type tinyBlock struct {
+	state uint64
+	mem   []byte
+	begin uintptr
+	next  []uint32 // index+1 of the next free cell of each free cell
+	free  []uint32 // 1 if the cell is free, so double free is detected
+}
+
+// NewTinyPool create a TinyPool that divide the chunks of chunkSize alloc from pool into cells of cellSize.
+func NewTinyPool(pool Pool, cellSize, chunkSize int) *TinyPool {
+	if cellSize < 1 || chunkSize < cellSize {
+		panic("slab.NewTinyPool: bad cellSize or chunkSize")
+	}
+	if chunkSize/cellSize > maxTinyCells {
+		panic("slab.NewTinyPool: too many cells in a chunk")
+	}
+	return &TinyPool{
+		pool:      pool,
+		cellSize:  cellSize,
+		chunkSize: chunkSize,
+		cells:     chunkSize / cellSize,
+	}
+}
+
+// TinyAlloc alloc a []byte of size from a cell, its capacity is limited to the cell.
+// The size larger than cellSize is alloc from the Pool.
+// It returns nil when all cells are in use and the Pool returns nil for a new chunk, see WithStrict.
+func (p *TinyPool) TinyAlloc(size int) []byte {
+	if size > p.cellSize {
+		return p.pool.Alloc(size)
+	}
+	for {
+		old := atomic.LoadPointer(&p.blocks)
+		blocks := p.loadBlocks(old)
+		hint := int(atomic.LoadUint32(&p.hint))
+		for k := 0; k < len(blocks); k++ {
+			j := (hint + k) % len(blocks)
+			b := blocks[j]
+			if i, ok := b.pop(); ok {
+				if k > 0 {
+					atomic.StoreUint32(&p.hint, uint32(j))
+				}
+				atomic.AddInt64(&p.inUse, 1)
+				begin := i * p.cellSize
+				return b.mem[begin : begin+size : begin+p.cellSize]
+			}
+		}
+		if !p.addBlock(old) {
+			return nil
+		}
+	}
+}
+
+// TinyFree release a []byte that alloc from TinyAlloc, the []byte not in a cell is released into the Pool.
+func (p *TinyPool) TinyFree(mem []byte) {
+	ptr := pointerOf(mem)
+	blocks := p.loadBlocks(atomic.LoadPointer(&p.blocks))
+	j := sort.Search(len(blocks), func(j int) bool {
+		return blocks[j].begin > ptr
+	}) - 1
+	if j < 0 || ptr >= blocks[j].begin+uintptr(p.cells*p.cellSize) {
+		p.pool.Free(mem)
+		return
+	}
+	b := blocks[j]
+	if (ptr-b.begin)%uintptr(p.cellSize) != 0 {
+		panic("slab.TinyPool: Bad Cell")
+	}
+	i := int((ptr - b.begin) / uintptr(p.cellSize))
+	if !atomic.CompareAndSwapUint32(&b.free[i], 0, 1) {
+		panic("slab.TinyPool: Double Free")
+	}
+	atomic.AddInt64(&p.inUse, -1)
+	if b.push(i) == p.cells && b.retire(p.cells) {
+		p.removeBlock(b)
+		p.pool.Free(b.mem)
+	}
+}
+
+// InUse returns the number of cells in use and the number of chunks alloc from the Pool.
+func (p *TinyPool) InUse() (cells, chunks int) {
+	return int(atomic.LoadInt64(&p.inUse)), len(p.loadBlocks(atomic.LoadPointer(&p.blocks)))
+}
+
+func (p *TinyPool) loadBlocks(ptr unsafe.Pointer) []*tinyBlock {
+	if ptr != nil {
+		return *(*[]*tinyBlock)(ptr)
+	}
+	return nil
+}
+
+// addBlock alloc a chunk from the Pool as a new block, unless the blocks are no longer old.
+// It returns false if the Pool returns nil for the chunk, see WithStrict.
+func (p *TinyPool) addBlock(old unsafe.Pointer) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if atomic.LoadPointer(&p.blocks) != old {
+		// a block is added or released since old is loaded, look for a free cell again.
+		return true
+	}
+	mem := p.pool.Alloc(p.chunkSize)
+	if mem == nil {
+		return false
+	}
+	b := &tinyBlock{
+		mem:   mem,
+		begin: pointerOf(mem),
+		next:  make([]uint32, p.cells),
+		free:  make([]uint32, p.cells),
+		state: uint64(p.cells)<<16 | 1,
+	}
+	for i := 0; i < p.cells; i++ {
+		// the first cell on the top.
+		if i < p.cells-1 {
+			b.next[i] = uint32(i + 2)
+		}
+		b.free[i] = 1
+	}
+	blocks := p.loadBlocks(old)
+	j := sort.Search(len(blocks), func(j int) bool {
+		return blocks[j].begin > b.begin
+	})
+	added := make([]*tinyBlock, 0, len(blocks)+1)
+	added = append(added, blocks[:j]...)
+	added = append(added, b)
+	added = append(added, blocks[j:]...)
+	atomic.StorePointer(&p.blocks, unsafe.Pointer(&added))
+	return true
+}
+
+// removeBlock take b out of the blocks, b must be retired.
+func (p *TinyPool) removeBlock(b *tinyBlock) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	blocks := p.loadBlocks(atomic.LoadPointer(&p.blocks))
+	kept := make([]*tinyBlock, 0, len(blocks))
+	for _, k := range blocks {
+		if k != b {
+			kept = append(kept, k)
+		}
+	}
+	atomic.StorePointer(&p.blocks, unsafe.Pointer(&kept))
+}
+
+// pop take a free cell from the block, it returns false if the block has no free cell.
+func (b *tinyBlock) pop() (int, bool) {
+	for {
+		old := atomic.LoadUint64(&b.state)
+		head := old & 0xFFFF
+		if head == 0 {
+			return 0, false
+		}
+		i := int(head - 1)
+		nxt := uint64(atomic.LoadUint32(&b.next[i]))
+		new := (old>>32+1)<<32 | (old>>16&0xFFFF-1)<<16 | nxt
+		if atomic.CompareAndSwapUint64(&b.state, old, new) {
+			atomic.StoreUint32(&b.free[i], 0)
+			return i, true
+		}
+		runtime.Gosched()
+	}
+}
+
+// push link cell i into the free list of the block, and returns the number of free cells after it.
+func (b *tinyBlock) push(i int) int {
+	for {
+		old := atomic.LoadUint64(&b.state)
+		atomic.StoreUint32(&b.next[i], uint32(old&0xFFFF))
+		count := old>>16&0xFFFF + 1
+		new := (old>>32+1)<<32 | count<<16 | uint64(i+1)
+		if atomic.CompareAndSwapUint64(&b.state, old, new) {
+			return int(count)
+		}
+		runtime.Gosched()
+	}
+}
+
+// retire empty the free list if all the cells of the block are free, so no TinyAlloc takes a cell from it again.
+// It returns false if a cell is taken in the meantime.
+func (b *tinyBlock) retire(cells int) bool {
+	for {
+		old := atomic.LoadUint64(&b.state)
+		if int(old>>16&0xFFFF) != cells {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&b.state, old, (old>>32+1)<<32) {
+			return true
+		}
+		runtime.Gosched()
+	}
+}
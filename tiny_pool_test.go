@@ -0,0 +1,140 @@
+package slab
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_TinyPool(t *testing.T) {
+	pool := NewAtomPool(64, 1024, 2, 1024)
+	tiny := NewTinyPool(pool, 8, 64)
+
+	var mems [][]byte
+	for i := 0; i < 20; i++ {
+		mem := tiny.TinyAlloc(i % 9)
+		utest.EqualNow(t, len(mem), i%9)
+		utest.EqualNow(t, cap(mem), 8)
+		mems = append(mems, mem)
+	}
+	cells, chunks := tiny.InUse()
+	utest.EqualNow(t, cells, 20)
+	utest.EqualNow(t, chunks, 3)
+	utest.EqualNow(t, pool.Stats().InUse, 3*64)
+
+	for _, mem := range mems[:8] {
+		tiny.TinyFree(mem)
+	}
+	cells, chunks = tiny.InUse()
+	utest.EqualNow(t, cells, 12)
+	utest.EqualNow(t, chunks, 2)
+	utest.EqualNow(t, pool.Stats().InUse, 2*64)
+
+	for _, mem := range mems[8:] {
+		tiny.TinyFree(mem)
+	}
+	cells, chunks = tiny.InUse()
+	utest.EqualNow(t, cells, 0)
+	utest.EqualNow(t, chunks, 0)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+
+	mem := tiny.TinyAlloc(100)
+	utest.EqualNow(t, cap(mem), 128)
+	tiny.TinyFree(mem)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+}
+
+func Test_TinyPool_DoubleFree(t *testing.T) {
+	tiny := NewTinyPool(NewAtomPool(64, 1024, 2, 1024), 8, 64)
+	tiny.TinyAlloc(8)
+	mem := tiny.TinyAlloc(8)
+	tiny.TinyFree(mem)
+
+	defer func() {
+		utest.EqualNow(t, recover(), "slab.TinyPool: Double Free")
+	}()
+	tiny.TinyFree(mem)
+}
+
+func Test_TinyPool_BadCell(t *testing.T) {
+	tiny := NewTinyPool(NewAtomPool(64, 1024, 2, 1024), 8, 64)
+	mem := tiny.TinyAlloc(8)
+
+	defer func() {
+		utest.EqualNow(t, recover(), "slab.TinyPool: Bad Cell")
+	}()
+	tiny.TinyFree(mem[1:])
+}
+
+func Test_TinyPool_Concurrent(t *testing.T) {
+	pool := NewAtomPool(64, 1024, 2, 64*1024)
+	tiny := NewTinyPool(pool, 8, 64)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(sentinel byte) {
+			defer wg.Done()
+			var mems [][]byte
+			for j := 0; j < 1000; j++ {
+				mem := tiny.TinyAlloc(8)
+				for k := range mem {
+					mem[k] = sentinel
+				}
+				mems = append(mems, mem)
+				if len(mems) == 20 {
+					for _, mem := range mems {
+						for k := range mem {
+							if mem[k] != sentinel {
+								t.Error("cell is shared")
+							}
+						}
+						tiny.TinyFree(mem)
+					}
+					mems = mems[:0]
+				}
+			}
+		}(byte(i))
+	}
+	wg.Wait()
+	cells, chunks := tiny.InUse()
+	utest.EqualNow(t, cells, 0)
+	utest.EqualNow(t, chunks, 0)
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+}
+
+func Test_TinyPool_TooManyCells(t *testing.T) {
+	defer func() {
+		utest.EqualNow(t, recover(), "slab.NewTinyPool: too many cells in a chunk")
+	}()
+	NewTinyPool(NewAtomPool(64, 128*1024, 2, 128*1024), 1, 64*1024)
+}
+
+func Benchmark_TinyPool_AllocAndFree(b *testing.B) {
+	pool := NewAtomPool(64, 1024, 2, 64*1024)
+	tiny := NewTinyPool(pool, 8, 1024)
+	// keep a cell in use so the chunk is not released by every TinyFree.
+	tiny.TinyAlloc(8)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tiny.TinyFree(tiny.TinyAlloc(8))
+		}
+	})
+}
+
+func Test_TinyPool_Strict(t *testing.T) {
+	pool := NewAtomPool(128, 128, 2, 128, WithStrict())
+	tiny := NewTinyPool(pool, 16, 128)
+	var mems [][]byte
+	for i := 0; i < 8; i++ {
+		mem := tiny.TinyAlloc(10)
+		utest.EqualNow(t, len(mem), 10)
+		mems = append(mems, mem)
+	}
+	utest.IsNilNow(t, tiny.TinyAlloc(10))
+	for _, mem := range mems {
+		tiny.TinyFree(mem)
+	}
+	utest.EqualNow(t, len(tiny.TinyAlloc(10)), 10)
+}
@@ -0,0 +1,84 @@
+package slab
+
+import "sort"
+
+// Tune suggests the NewAtomPool arguments for a recorded size histogram,
+// the keys are Alloc sizes and the values are the number of those buffers in use at the same time.
+// maxSize is the smallest recorded size that keeps the part of the buffers larger than it
+// not more than targetFallbackRate, they are left to fallback.
+// A targetFallbackRate below one buffer of the histogram, e.g. 1e-9, leaves no buffer to fallback.
+// Then minSize and factor are searched to minimize the total memory of the pages,
+// pageSize is the memory needed by the busiest class so that no class runs out of chunks.
+// An empty histogram gets the arguments in README. It panics if targetFallbackRate is not positive.
+func Tune(histogram map[int]uint64, targetFallbackRate float64) (minSize, maxSize, factor, pageSize int) {
+	if !(targetFallbackRate > 0) {
+		panic("slab.Tune: targetFallbackRate must be positive")
+	}
+	counted := make(map[int]uint64, len(histogram))
+	total := uint64(0)
+	for size, count := range histogram {
+		if size < 1 {
+			size = 1
+		}
+		if count > 0 {
+			counted[size] += count
+			total += count
+		}
+	}
+	if total == 0 {
+		return 64, 64 * 1024, 2, 1024 * 1024
+	}
+	sizes := make([]int, 0, len(counted))
+	for size := range counted {
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+	counts := make([]uint64, len(sizes))
+	for i, size := range sizes {
+		counts[i] = counted[size]
+	}
+
+	// the buffers larger than maxSize fallback.
+	above := total
+	for i, size := range sizes {
+		above -= counts[i]
+		if float64(above) <= targetFallbackRate*float64(total) {
+			maxSize = size
+			break
+		}
+	}
+
+	bestMemory := -1
+	bestClasses := 0
+	for min := 1; ; min *= 2 {
+		if min > maxSize {
+			min = maxSize
+		}
+		for f := 2; f <= 8; f++ {
+			classes := classSizes(min, maxSize, f, maxSize)
+			page := maxSize
+			used := make([]int, len(classes))
+			for i, size := range sizes {
+				if size > maxSize {
+					break
+				}
+				c := sort.SearchInts(classes, size)
+				used[c] += int(counts[i])
+			}
+			for c, n := range used {
+				if n*classes[c] > page {
+					page = n * classes[c]
+				}
+			}
+			memory := page * len(classes)
+			if bestMemory < 0 || memory < bestMemory || memory == bestMemory && len(classes) < bestClasses {
+				bestMemory, bestClasses = memory, len(classes)
+				minSize, factor, pageSize = min, f, page
+			}
+		}
+		if min == maxSize {
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_Tune(t *testing.T) {
+	minSize, maxSize, factor, pageSize := Tune(nil, 0.001)
+	utest.EqualNow(t, minSize, 64)
+	utest.EqualNow(t, maxSize, 64*1024)
+	utest.EqualNow(t, factor, 2)
+	utest.EqualNow(t, pageSize, 1024*1024)
+
+	// a single size gets a single class that has exactly the buffers.
+	minSize, maxSize, factor, pageSize = Tune(map[int]uint64{100: 50}, 1e-9)
+	utest.EqualNow(t, minSize, 100)
+	utest.EqualNow(t, maxSize, 100)
+	utest.EqualNow(t, pageSize, 100*50)
+
+	histogram := map[int]uint64{
+		0:     10,
+		64:    1000,
+		200:   500,
+		1000:  100,
+		4000:  10,
+		60000: 1,
+	}
+	minSize, maxSize, factor, pageSize = Tune(histogram, 1e-9)
+	utest.EqualNow(t, maxSize, 60000)
+	utest.Assert(t, factor >= 2)
+
+	pool := NewAtomPool(minSize, maxSize, factor, pageSize)
+	var mems [][]byte
+	for size, count := range histogram {
+		for i := uint64(0); i < count; i++ {
+			mems = append(mems, pool.Alloc(size))
+		}
+	}
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(0))
+	for _, mem := range mems {
+		pool.Free(mem)
+	}
+
+	// the single 60000 buffer is less than 0.1% of the buffers.
+	_, maxSize, _, _ = Tune(histogram, 0.001)
+	utest.EqualNow(t, maxSize, 4000)
+	_, maxSize, _, _ = Tune(histogram, 1)
+	utest.EqualNow(t, maxSize, 1)
+}
+
+func Test_Tune_BadRate(t *testing.T) {
+	for _, rate := range []float64{0, -0.1} {
+		func() {
+			defer func() {
+				utest.EqualNow(t, recover(), "slab.Tune: targetFallbackRate must be positive")
+			}()
+			Tune(map[int]uint64{100: 50}, rate)
+		}()
+	}
+}
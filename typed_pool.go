@@ -0,0 +1,71 @@
+//go:build go1.18
+// +build go1.18
+
+package slab
+
+import (
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// TypedPool is a lock-free slab of fixed-size T, the objects are carved out of the pages of an AtomPool class.
+// T must not contain pointers, because the garbage collector never scans the pool memory.
+type TypedPool[T any] struct {
+	pool *AtomPool
+	size int
+}
+
+// NewTypedPool create a TypedPool that each page holds perPage objects of T,
+// options are applied to the AtomPool under it, e.g. WithMaxPages to let it grow.
+func NewTypedPool[T any](perPage int, options ...Option) *TypedPool[T] {
+	var zero T
+	t := reflect.TypeOf(&zero).Elem()
+	if hasPointers(t) {
+		panic("slab.NewTypedPool: " + t.String() + " contains pointers")
+	}
+	if perPage < 1 {
+		panic("slab.NewTypedPool: perPage must be at least 1")
+	}
+	// round the size up to the alignment, so every chunk is aligned as T requires.
+	size, align := int(unsafe.Sizeof(zero)), int(unsafe.Alignof(zero))
+	size = (size + align - 1) / align * align
+	if size == 0 {
+		size = align
+	}
+	return &TypedPool[T]{
+		pool: newAtomPool([]int{size}, size, size, perPage*size, nil, options),
+		size: size,
+	}
+}
+
+// Alloc returns a zero T from the pool, or by new() if the pool is empty.
+// With WithStrict it returns nil if the pool is empty.
+func (tp *TypedPool[T]) Alloc() *T {
+	mem, _ := tp.pool.pop(0, tp.size)
+	if mem == nil {
+		atomic.AddUint64(&tp.pool.fallbacks, 1)
+		if tp.pool.strict {
+			return nil
+		}
+		return new(T)
+	}
+	p := (*T)(unsafe.Pointer(&mem[0]))
+	var zero T
+	*p = zero
+	return p
+}
+
+// Free release a *T that alloc from Alloc, the *T made by new() is left to the garbage collector.
+// Like AtomPool.Free, it panics for a pointer into the middle of an object or a double free, and it ignores nil.
+func (tp *TypedPool[T]) Free(p *T) {
+	if p == nil {
+		return
+	}
+	tp.pool.free(unsafe.Slice((*byte)(unsafe.Pointer(p)), tp.size), tp.size)
+}
+
+// Stats returns a snapshot of the counters of the pool, it has one class of the object size.
+func (tp *TypedPool[T]) Stats() Stats {
+	return tp.pool.Stats()
+}
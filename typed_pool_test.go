@@ -0,0 +1,117 @@
+//go:build go1.18
+// +build go1.18
+
+package slab
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/funny/utest"
+)
+
+type testHeader struct {
+	ID   uint32
+	Kind uint8
+	Size uint64
+}
+
+func Test_TypedPool(t *testing.T) {
+	pool := NewTypedPool[testHeader](4)
+	var hs []*testHeader
+	for i := 0; i < 4; i++ {
+		h := pool.Alloc()
+		utest.EqualNow(t, *h, testHeader{})
+		utest.EqualNow(t, uintptr(unsafe.Pointer(h))%unsafe.Alignof(*h), uintptr(0))
+		h.ID = uint32(i)
+		h.Size = 100
+		hs = append(hs, h)
+	}
+	for i, h := range hs {
+		utest.EqualNow(t, h.ID, uint32(i))
+	}
+	h := pool.Alloc()
+	utest.EqualNow(t, pool.Stats().Fallbacks, uint64(1))
+	pool.Free(h)
+
+	pool.Free(hs[0])
+	h = pool.Alloc()
+	utest.EqualNow(t, h, hs[0])
+	utest.EqualNow(t, *h, testHeader{})
+	for _, h := range hs {
+		pool.Free(h)
+	}
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+	utest.IsNilNow(t, pool.pool.CheckInvariants())
+}
+
+func Test_TypedPool_Small(t *testing.T) {
+	pool := NewTypedPool[[3]byte](10)
+	utest.EqualNow(t, pool.size, 3)
+	pool.Free(pool.Alloc())
+	empty := NewTypedPool[struct{}](10)
+	utest.EqualNow(t, empty.size, 1)
+	empty.Free(empty.Alloc())
+}
+
+func Test_TypedPool_Concurrent(t *testing.T) {
+	pool := NewTypedPool[testHeader](64, WithMaxPages(4))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id uint32) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				h := pool.Alloc()
+				h.ID = id
+				if h.ID != id {
+					panic("shared object")
+				}
+				pool.Free(h)
+			}
+		}(uint32(i))
+	}
+	wg.Wait()
+	utest.EqualNow(t, pool.Stats().InUse, 0)
+}
+
+func Test_TypedPool_DoubleFree(t *testing.T) {
+	if !chunkChecks {
+		t.Skip("the chunk checks are stripped by slab_unsafe")
+	}
+	pool := NewTypedPool[testHeader](4)
+	h := pool.Alloc()
+	pool.Free(h)
+	defer func() {
+		utest.EqualNow(t, recover(), "slab.AtomPool: Double Free")
+	}()
+	pool.Free(h)
+}
+
+func Test_TypedPool_Strict(t *testing.T) {
+	pool := NewTypedPool[testHeader](1, WithStrict())
+	h := pool.Alloc()
+	utest.NotNilNow(t, h)
+	utest.IsNilNow(t, pool.Alloc())
+	pool.Free(nil)
+	pool.Free(h)
+	utest.NotNilNow(t, pool.Alloc())
+}
+
+func Test_TypedPool_Pointers(t *testing.T) {
+	defer func() {
+		utest.NotNilNow(t, recover())
+	}()
+	NewTypedPool[*int](10)
+}
+
+func Benchmark_TypedPool_AllocAndFree(b *testing.B) {
+	pool := NewTypedPool[testHeader](1024)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Free(pool.Alloc())
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package slab
+
+import "unsafe"
+
+// UnsafePool is a slab allocation memory pool without any synchronization.
+// It is NOT safe for concurrent use, it's for the goroutine that owns its pool entirely.
+type UnsafePool struct {
+	classes []class
+	minSize int
+	maxSize int
+}
+
+// NewUnsafePool create a slab allocation memory pool that is not safe for concurrent use.
+// minSize is the smallest chunk size.
+// maxSize is the lagest chunk size, it's a class of its own when the growth from minSize doesn't hit it.
+// factor is used to control growth of chunk size.
+// pageSize is the memory size of each slab class.
+func NewUnsafePool(minSize, maxSize, factor, pageSize int) *UnsafePool {
+	return &UnsafePool{newClasses(classSizes(minSize, maxSize, factor, pageSize), pageSize), minSize, maxSize}
+}
+
+// Alloc try alloc a []byte from internal slab class if no free chunk in slab class Alloc will make one.
+func (pool *UnsafePool) Alloc(size int) []byte {
+	if size <= pool.maxSize {
+		if i := searchClass(pool.classes, 0, size); i >= 0 {
+			if mem := pool.classes[i].unsafePop(); mem != nil {
+				return mem[:size]
+			}
+		}
+	}
+	return make([]byte, size)
+}
+
+// Free release a []byte that alloc from Pool.Alloc.
+func (pool *UnsafePool) Free(mem []byte) {
+	if i := exactClass(pool.classes, cap(mem)); i >= 0 {
+		pool.classes[i].unsafePush(mem)
+	}
+}
+
+func (c *class) unsafePush(mem []byte) {
+	ptr := pointerOf(mem)
+	if i := c.chunkIndex(ptr); i >= 0 {
+		chk := c.chunkAt(i)
+		if chunkChecks {
+			if uintptr(unsafe.Pointer(&chk.mem[0])) != ptr {
+				panic("slab.UnsafePool: Bad Chunk")
+			}
+			if chk.next != 0 {
+				panic("slab.UnsafePool: Double Free")
+			}
+		}
+		sanitizeFree(chk.mem)
+		chk.aba++
+		chk.next = c.head
+		c.head = uint64(i+1)<<32 + uint64(chk.aba)
+	}
+}
+
+func (c *class) unsafePop() []byte {
+	if c.head == 0 {
+		return nil
+	}
+	chk := &c.chunks[c.head>>32-1]
+	c.head = chk.next
+	chk.next = 0
+	sanitizeAlloc(chk.mem)
+	return chk.mem
+}
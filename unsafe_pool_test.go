@@ -0,0 +1,91 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_UnsafePool_AllocAndFree(t *testing.T) {
+	pool := NewUnsafePool(128, 64*1024, 2, 1024*1024)
+	for i := 0; i < len(pool.classes); i++ {
+		temp := make([][]byte, len(pool.classes[i].chunks))
+
+		for j := 0; j < len(temp); j++ {
+			mem := pool.Alloc(pool.classes[i].size)
+			utest.EqualNow(t, cap(mem), pool.classes[i].size)
+			temp[j] = mem
+		}
+		utest.Assert(t, pool.classes[i].head == 0)
+
+		for j := 0; j < len(temp); j++ {
+			pool.Free(temp[j])
+		}
+		utest.Assert(t, pool.classes[i].head != 0)
+	}
+}
+
+func Test_UnsafePool_AllocSmall(t *testing.T) {
+	pool := NewUnsafePool(128, 1024, 2, 1024)
+	mem := pool.Alloc(64)
+	utest.EqualNow(t, len(mem), 64)
+	utest.EqualNow(t, cap(mem), 128)
+	pool.Free(mem)
+}
+
+func Test_UnsafePool_AllocLarge(t *testing.T) {
+	pool := NewUnsafePool(128, 1024, 2, 1024)
+	mem := pool.Alloc(2048)
+	utest.EqualNow(t, len(mem), 2048)
+	utest.EqualNow(t, cap(mem), 2048)
+	pool.Free(mem)
+}
+
+func Test_UnsafePool_DoubleFree(t *testing.T) {
+	if !chunkChecks {
+		t.Skip("the chunk checks are stripped by slab_unsafe")
+	}
+	pool := NewUnsafePool(128, 1024, 2, 1024)
+	mem := pool.Alloc(64)
+	func() {
+		defer func() {
+			utest.NotNilNow(t, recover())
+		}()
+		pool.Free(mem)
+		pool.Free(mem)
+	}()
+}
+
+func Test_UnsafePool_AllocSlow(t *testing.T) {
+	pool := NewUnsafePool(128, 1024, 2, 1024)
+	mem := pool.classes[len(pool.classes)-1].unsafePop()
+	utest.EqualNow(t, cap(mem), 1024)
+	utest.Assert(t, pool.classes[len(pool.classes)-1].head == 0)
+
+	mem = pool.Alloc(1024)
+	utest.EqualNow(t, cap(mem), 1024)
+}
+
+func Benchmark_UnsafePool_AllocAndFree_128(b *testing.B) {
+	pool := NewUnsafePool(128, 1024, 2, 64*1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.Free(pool.Alloc(128))
+	}
+}
+
+func Benchmark_UnsafePool_AllocAndFree_256(b *testing.B) {
+	pool := NewUnsafePool(128, 1024, 2, 64*1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.Free(pool.Alloc(256))
+	}
+}
+
+func Benchmark_UnsafePool_AllocAndFree_512(b *testing.B) {
+	pool := NewUnsafePool(128, 1024, 2, 64*1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.Free(pool.Alloc(512))
+	}
+}
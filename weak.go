@@ -0,0 +1,44 @@
+package slab
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// WeakRef refers to a pooled []byte without keeping it, Get tells whether the []byte is freed since the WeakRef made.
+// It's for the caches that keep pooled []byte and let them be freed elsewhere, e.g. to reclaim memory under pressure.
+// The pool must be created with WithEpochs.
+type WeakRef struct {
+	mem   []byte
+	chk   *chunk
+	epoch uint32
+}
+
+// WeakRef make a WeakRef of mem, mem must be alloc from the pool and not freed yet.
+// The []byte not from a slab class is never reused, its WeakRef is always valid.
+func (pool *AtomPool) WeakRef(mem []byte) WeakRef {
+	if !pool.epochs {
+		panic("slab.AtomPool: WeakRef needs WithEpochs")
+	}
+	ptr := pointerOf(mem)
+	for p := pool; p != nil; p = p.grownPool() {
+		if i := p.classForPointer(ptr); i >= 0 {
+			c := &p.classes[i]
+			chk := c.chunkAt(c.chunkIndex(ptr))
+			if uintptr(unsafe.Pointer(&chk.mem[0])) != ptr || atomic.LoadUint64(&chk.next) != 0 {
+				panic("slab.AtomPool: Bad Chunk")
+			}
+			return WeakRef{mem, chk, atomic.LoadUint32(&chk.epoch)}
+		}
+	}
+	return WeakRef{mem: mem}
+}
+
+// Get returns the []byte and true if it's not freed since the WeakRef made, or nil and false if it's freed.
+// Get can't stop the []byte being freed after it returns, the owner of the cache must order Get and Free.
+func (r WeakRef) Get() ([]byte, bool) {
+	if r.chk != nil && atomic.LoadUint32(&r.chk.epoch) != r.epoch {
+		return nil, false
+	}
+	return r.mem, r.mem != nil
+}
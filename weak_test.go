@@ -0,0 +1,54 @@
+package slab
+
+import (
+	"testing"
+
+	"github.com/funny/utest"
+)
+
+func Test_WeakRef(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithEpochs())
+	mem := pool.Alloc(100)
+	ref := pool.WeakRef(mem)
+	got, ok := ref.Get()
+	utest.Assert(t, ok)
+	utest.EqualNow(t, len(got), 100)
+
+	pool.Free(mem)
+	_, ok = ref.Get()
+	utest.Assert(t, !ok)
+
+	// the chunk is reused.
+	mem2 := pool.Alloc(100)
+	utest.EqualNow(t, pointerOf(mem2), pointerOf(mem))
+	_, ok = ref.Get()
+	utest.Assert(t, !ok)
+	got, ok = pool.WeakRef(mem2).Get()
+	utest.Assert(t, ok)
+	pool.Free(got)
+
+	big := pool.Alloc(2000)
+	got, ok = pool.WeakRef(big).Get()
+	utest.Assert(t, ok)
+	utest.EqualNow(t, len(got), 2000)
+
+	_, ok = WeakRef{}.Get()
+	utest.Assert(t, !ok)
+}
+
+func Test_WeakRef_FIFO(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024, WithEpochs(), WithFIFO())
+	mem := pool.Alloc(100)
+	ref := pool.WeakRef(mem)
+	pool.Free(mem)
+	_, ok := ref.Get()
+	utest.Assert(t, !ok)
+}
+
+func Test_WeakRef_NoEpochs(t *testing.T) {
+	pool := NewAtomPool(128, 1024, 2, 1024)
+	defer func() {
+		utest.EqualNow(t, recover(), "slab.AtomPool: WeakRef needs WithEpochs")
+	}()
+	pool.WeakRef(pool.Alloc(100))
+}